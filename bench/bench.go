@@ -0,0 +1,379 @@
+// Package bench implements load tests for BitTorrent trackers.
+//
+// A load test simulates peers going through realistic lifecycles: Every peer
+// announces started, a number of regular announces, completed and finally
+// stopped.
+//
+// Load tests run either closed-loop, where a number of workers send announces
+// as fast as the tracker answers them, or open-loop, where announces are sent
+// at a given rate regardless of how fast the tracker answers.
+package bench
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// Config configures a load test.
+type Config struct {
+	// Workers is the number of concurrent workers.
+	// For open-loop load tests, this is the maximum number of announces in
+	// flight.
+	Workers int
+
+	// InfoHashes is the number of infohashes the peers are distributed
+	// across.
+	InfoHashes int
+
+	// Duration is the time after which the load test stops.
+	// Zero means no time limit.
+	Duration time.Duration
+
+	// Requests is the number of announces after which the load test stops.
+	// Zero means no limit.
+	Requests int
+
+	// Announces is the number of regular announces every peer performs
+	// between its started and completed announces.
+	Announces int
+
+	// Numwant is the numwant sent with every announce.
+	Numwant int
+}
+
+// DefaultConfig returns the default Config.
+func DefaultConfig() Config {
+	return Config{
+		Workers:    10,
+		InfoHashes: 100,
+		Duration:   10 * time.Second,
+		Announces:  3,
+		Numwant:    50,
+	}
+}
+
+// ErrNoLimit is returned if a load test has neither a time nor a request
+// limit.
+var ErrNoLimit = errors.New("load test needs a duration or request limit")
+
+// Latencies summarizes the latencies of a load test.
+type Latencies struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Result represents the result of a load test.
+//
+// Durations are encoded as nanoseconds in JSON.
+type Result struct {
+	Protocol   string        `json:"protocol"`
+	Tracker    string        `json:"tracker"`
+	Requests   int           `json:"requests"`
+	Errors     int           `json:"errors"`
+	Warnings   int           `json:"warnings"`
+	Timeouts   int           `json:"timeouts"`
+	Duration   time.Duration `json:"duration"`
+	Throughput float64       `json:"throughput"`
+	Latencies  Latencies     `json:"latencies"`
+	Histogram  *Histogram    `json:"histogram"`
+}
+
+// ErrorRate returns the fraction of requests that failed, including timeouts.
+func (r Result) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors+r.Timeouts) / float64(r.Requests)
+}
+
+// WarningRate returns the fraction of requests that returned a warning.
+func (r Result) WarningRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Warnings) / float64(r.Requests)
+}
+
+// recorder records the outcomes of announces.
+// It is safe for concurrent use.
+type recorder struct {
+	mu        sync.Mutex
+	latencies *Histogram
+	errors    int
+	warnings  int
+	timeouts  int
+}
+
+// record records the outcome of an announce that took latency.
+func (r *recorder) record(latency time.Duration, resp poke.OptionalAnnounceResponse, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.latencies.Record(latency)
+	switch {
+	case err != nil && isTimeout(err):
+		r.timeouts++
+	case err != nil:
+		r.errors++
+	default:
+		switch resp.(type) {
+		case poke.ErrorResponse:
+			r.errors++
+		case poke.WarningResponse:
+			r.warnings++
+		}
+	}
+}
+
+// result creates the Result of a load test that took d.
+func (r *recorder) result(d time.Duration) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := &Result{
+		Requests:  int(r.latencies.Count()),
+		Errors:    r.errors,
+		Warnings:  r.warnings,
+		Timeouts:  r.timeouts,
+		Duration:  d,
+		Latencies: summarize(r.latencies),
+		Histogram: r.latencies,
+	}
+	res.Throughput = float64(res.Requests) / d.Seconds()
+	return res
+}
+
+// bench is the state shared by all workers of a load test.
+type bench struct {
+	cfg        Config
+	infoHashes []poke.InfoHash
+	rec        recorder
+
+	// requests is the number of requests claimed by workers.
+	requests int64
+
+	// peers is the number of peers created by workers.
+	peers int64
+
+	// genMu serializes generating peers, so the Generator can be reset when
+	// it runs out of them.
+	genMu sync.Mutex
+	gen   *poke.Generator
+}
+
+// newBench validates cfg and prepares a load test.
+func newBench(cfg Config) (*bench, error) {
+	if cfg.Duration <= 0 && cfg.Requests <= 0 {
+		return nil, ErrNoLimit
+	}
+	if cfg.Workers <= 0 || cfg.InfoHashes <= 0 {
+		return nil, errors.New("load test needs at least one worker and infohash")
+	}
+
+	b := &bench{
+		cfg:        cfg,
+		infoHashes: make([]poke.InfoHash, cfg.InfoHashes),
+		rec: recorder{
+			latencies: NewHistogram(),
+		},
+	}
+	b.gen = poke.NewGenerator(time.Now().UnixNano())
+	for i := range b.infoHashes {
+		b.infoHashes[i] = b.gen.InfoHash()
+	}
+
+	return b, nil
+}
+
+// newAnnouncers creates an announcer per worker.
+func (b *bench) newAnnouncers(newAnnouncer func() (poke.Announcer, error)) ([]poke.Announcer, error) {
+	announcers := make([]poke.Announcer, 0, b.cfg.Workers)
+	for i := 0; i < b.cfg.Workers; i++ {
+		a, err := newAnnouncer()
+		if err != nil {
+			closeAnnouncers(announcers)
+			return nil, poke.WrapError("unable to create announcer", err)
+		}
+		announcers = append(announcers, a)
+	}
+	return announcers, nil
+}
+
+// closeAnnouncers closes the announcers that hold resources, like the
+// sockets of UDP clients.
+func closeAnnouncers(announcers []poke.Announcer) {
+	for _, a := range announcers {
+		if c, ok := a.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// claim claims a request, reporting false if the request limit was reached.
+func (b *bench) claim() bool {
+	n := atomic.AddInt64(&b.requests, 1)
+	return b.cfg.Requests <= 0 || n <= int64(b.cfg.Requests)
+}
+
+// Run runs a closed-loop load test: Every worker sends its next announce as
+// soon as it received the response to the previous one.
+// newAnnouncer is called once per worker.
+//
+// Latencies are measured from sending a request to receiving its response.
+// They under-report the latency real clients see if the tracker stalls,
+// because the workers stop sending while they wait. Use RunRate to avoid this.
+//
+// The load test stops after the configured duration or number of requests,
+// or when ctx is done.
+func Run(ctx context.Context, newAnnouncer func() (poke.Announcer, error), cfg Config) (*Result, error) {
+	b, err := newBench(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	announcers, err := b.newAnnouncers(newAnnouncer)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAnnouncers(announcers)
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, a := range announcers {
+		wg.Add(1)
+		go func(a poke.Announcer) {
+			defer wg.Done()
+			b.run(ctx, a)
+		}(a)
+	}
+	wg.Wait()
+
+	return b.rec.result(time.Since(start)), nil
+}
+
+// run runs peer lifecycles until the load test is over.
+func (b *bench) run(ctx context.Context, a poke.Announcer) {
+	for {
+		s := b.newSession()
+		for {
+			req, ok := s.next()
+			if !ok {
+				break
+			}
+			if ctx.Err() != nil || !b.claim() {
+				return
+			}
+
+			start := time.Now()
+			resp, err := announce(ctx, a, req)
+			if err != nil && ctx.Err() != nil {
+				// Aborted at the end of the load test, don't count it.
+				return
+			}
+			b.rec.record(time.Since(start), resp, err)
+		}
+	}
+}
+
+// peer generates the peer of a new session.
+// The Generator is reset when it runs out of unique peers, which reuses the
+// peers of sessions that ended long ago.
+func (b *bench) peer() poke.Peer {
+	b.genMu.Lock()
+	defer b.genMu.Unlock()
+
+	if b.gen.PeersLeft() == 0 {
+		b.gen.Reset()
+	}
+	return b.gen.Peer()
+}
+
+// session is a simulated peer going through its lifecycle.
+type session struct {
+	req    poke.AnnounceRequest
+	events []poke.Event
+}
+
+// newSession creates a session for a new peer.
+func (b *bench) newSession() *session {
+	i := int(atomic.AddInt64(&b.peers, 1) - 1)
+	s := &session{
+		req: poke.AnnounceRequest{
+			InfoHash: b.infoHashes[i%len(b.infoHashes)],
+			Peer:     b.peer(),
+			Numwant:  b.cfg.Numwant,
+			Compact:  true,
+			Left:     100 * (b.cfg.Announces + 1),
+		},
+		events: []poke.Event{poke.EventStarted},
+	}
+	for j := 0; j < b.cfg.Announces; j++ {
+		s.events = append(s.events, poke.EventNone)
+	}
+	s.events = append(s.events, poke.EventCompleted, poke.EventStopped)
+
+	return s
+}
+
+// next returns the next announce of the peer, reporting false if its
+// lifecycle is over.
+func (s *session) next() (poke.AnnounceRequest, bool) {
+	if len(s.events) == 0 {
+		return poke.AnnounceRequest{}, false
+	}
+
+	req := s.req
+	req.Event = s.events[0]
+	s.events = s.events[1:]
+
+	switch req.Event {
+	case poke.EventCompleted:
+		s.req.Downloaded += s.req.Left
+		s.req.Left = 0
+	case poke.EventStarted, poke.EventNone:
+		s.req.Downloaded += 100
+		s.req.Left -= 100
+	}
+
+	return req, true
+}
+
+// announce performs an announce, aborting it when ctx is done if a supports
+// that.
+func announce(ctx context.Context, a poke.Announcer, req poke.AnnounceRequest) (poke.OptionalAnnounceResponse, error) {
+	if ca, ok := a.(poke.ContextAnnouncer); ok {
+		return ca.AnnounceContext(ctx, req)
+	}
+	return a.Announce(req)
+}
+
+// isTimeout reports whether err was caused by a timeout.
+// The clients wrap errors as strings, so the type is lost.
+func isTimeout(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "timeout")
+}
+
+// summarize calculates the latency percentiles.
+func summarize(h *Histogram) Latencies {
+	return Latencies{
+		P50: h.Quantile(0.5),
+		P90: h.Quantile(0.9),
+		P99: h.Quantile(0.99),
+		Max: h.Max(),
+	}
+}
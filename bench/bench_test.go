@@ -0,0 +1,146 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+	"github.com/mrd0ll4r/poke/tracker"
+)
+
+func TestRunRequests(t *testing.T) {
+	tr := tracker.New(tracker.DefaultConfig())
+	cfg := Config{
+		Workers:    4,
+		InfoHashes: 3,
+		Requests:   1000,
+		Announces:  2,
+		Numwant:    10,
+	}
+
+	res, err := Run(context.Background(), func() (poke.Announcer, error) { return tr, nil }, cfg)
+	require.Nil(t, err)
+
+	assert.Equal(t, 1000, res.Requests)
+	assert.Equal(t, 0, res.Errors)
+	assert.Equal(t, 0, res.Warnings)
+	assert.Equal(t, 0, res.Timeouts)
+	assert.True(t, res.Throughput > 0)
+	assert.True(t, res.Latencies.P50 <= res.Latencies.P90)
+	assert.True(t, res.Latencies.P90 <= res.Latencies.P99)
+	assert.True(t, res.Latencies.P99 <= res.Latencies.Max)
+}
+
+func TestRunDuration(t *testing.T) {
+	tr := tracker.New(tracker.DefaultConfig())
+	s := httptest.NewServer(tr)
+	defer s.Close()
+
+	cfg := DefaultConfig()
+	cfg.Workers = 2
+	cfg.Duration = 200 * time.Millisecond
+
+	res, err := Run(context.Background(), func() (poke.Announcer, error) {
+		return http.NewClient(s.URL + "/announce")
+	}, cfg)
+	require.Nil(t, err)
+
+	assert.True(t, res.Requests > 0)
+	assert.Equal(t, 0, res.Errors)
+	assert.True(t, res.Duration >= cfg.Duration)
+}
+
+type failingAnnouncer struct {
+	err error
+}
+
+func (a failingAnnouncer) Announce(poke.AnnounceRequest) (poke.OptionalAnnounceResponse, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return poke.WarningResponse("slow down"), nil
+}
+
+func TestRunFailures(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Workers = 1
+	cfg.Duration = 0
+	cfg.Requests = 10
+
+	for _, tc := range []struct {
+		a                          failingAnnouncer
+		errors, warnings, timeouts int
+	}{
+		{failingAnnouncer{errors.New("connection refused")}, 10, 0, 0},
+		{failingAnnouncer{errors.New("announce: I/O timeout on receive")}, 0, 0, 10},
+		{failingAnnouncer{}, 0, 10, 0},
+	} {
+		res, err := Run(context.Background(), func() (poke.Announcer, error) { return tc.a, nil }, cfg)
+		require.Nil(t, err)
+		assert.Equal(t, 10, res.Requests)
+		assert.Equal(t, tc.errors, res.Errors)
+		assert.Equal(t, tc.warnings, res.Warnings)
+		assert.Equal(t, tc.timeouts, res.Timeouts)
+	}
+}
+
+func TestRunNoLimit(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Duration = 0
+
+	_, err := Run(context.Background(), func() (poke.Announcer, error) { return failingAnnouncer{}, nil }, cfg)
+	assert.Equal(t, ErrNoLimit, err)
+}
+
+// closingAnnouncer counts how often it was closed.
+type closingAnnouncer struct {
+	poke.Announcer
+	closed *int32
+}
+
+func (a closingAnnouncer) Close() error {
+	atomic.AddInt32(a.closed, 1)
+	return nil
+}
+
+func TestRunClosesAnnouncers(t *testing.T) {
+	tr := tracker.New(tracker.DefaultConfig())
+	cfg := Config{
+		Workers:    3,
+		InfoHashes: 1,
+		Requests:   10,
+	}
+
+	var closed int32
+	newAnnouncer := func() (poke.Announcer, error) {
+		return closingAnnouncer{tr, &closed}, nil
+	}
+
+	_, err := Run(context.Background(), newAnnouncer, cfg)
+	require.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&closed))
+
+	_, err = RunRate(context.Background(), newAnnouncer, cfg, Constant(1000))
+	require.Nil(t, err)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&closed))
+
+	// Announcers created before one failed are closed as well.
+	n := 0
+	_, err = Run(context.Background(), func() (poke.Announcer, error) {
+		n++
+		if n == 3 {
+			return nil, errors.New("no more announcers")
+		}
+		return newAnnouncer()
+	}, cfg)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(8), atomic.LoadInt32(&closed))
+}
@@ -0,0 +1,280 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBuckets is the number of linear sub-buckets per power of two.
+// Values are recorded with a relative error of less than 1/subBuckets.
+const (
+	subBucketBits = 7
+	subBuckets    = 1 << subBucketBits
+)
+
+// Histogram is a latency histogram in the style of HdrHistogram.
+//
+// Values are counted in buckets that grow exponentially with a fixed number of
+// linear sub-buckets each, so the histogram has constant relative precision
+// across the whole range of durations while using little memory.
+//
+// A Histogram is not safe for concurrent use.
+type Histogram struct {
+	counts []int64
+	total  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// NewHistogram creates a new, empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// bucketIndex returns the index of the bucket v is counted in.
+func bucketIndex(v int64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	e := bits.Len64(uint64(v)) - subBucketBits - 1
+	return e*subBuckets + int(v>>uint(e))
+}
+
+// bucketBounds returns the lower (inclusive) and upper (exclusive) bounds of
+// the bucket with index i.
+func bucketBounds(i int) (lower, upper int64) {
+	if i < subBuckets {
+		return int64(i), int64(i) + 1
+	}
+	e := uint(i/subBuckets - 1)
+	m := int64(i%subBuckets + subBuckets)
+	return m << e, (m + 1) << e
+}
+
+// Record records a duration.
+// Negative durations are recorded as zero.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	i := bucketIndex(int64(d))
+	if i >= len(h.counts) {
+		counts := make([]int64, i+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[i]++
+
+	if h.total == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.total++
+	h.sum += d
+}
+
+// Merge adds all values recorded in other to h.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.total == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		counts := make([]int64, len(other.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+
+	if h.total == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.total += other.total
+	h.sum += other.sum
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() int64 {
+	return h.total
+}
+
+// Min returns the smallest recorded value.
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the largest recorded value.
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Mean returns the mean of the recorded values.
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.total)
+}
+
+// Quantile returns the value below which the fraction q of the recorded values
+// fall, for q in [0, 1].
+//
+// The returned value is the largest value counted in the same bucket as the
+// exact quantile, but never larger than Max. Quantile(0) returns Min.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.min
+	}
+
+	rank := int64(math.Ceil(q * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			_, upper := bucketBounds(i)
+			d := time.Duration(upper - 1)
+			if d > h.max {
+				d = h.max
+			}
+			if d < h.min {
+				d = h.min
+			}
+			return d
+		}
+	}
+
+	return h.max
+}
+
+// Bucket is a non-empty bucket of a Histogram.
+type Bucket struct {
+	Lower time.Duration `json:"lower"`
+	Upper time.Duration `json:"upper"`
+	Count int64         `json:"count"`
+}
+
+// Buckets returns the non-empty buckets of the histogram, in ascending order.
+func (h *Histogram) Buckets() []Bucket {
+	var buckets []Bucket
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		lower, upper := bucketBounds(i)
+		buckets = append(buckets, Bucket{
+			Lower: time.Duration(lower),
+			Upper: time.Duration(upper),
+			Count: c,
+		})
+	}
+	return buckets
+}
+
+// histogramJSON is the JSON representation of a Histogram.
+// Durations are encoded as nanoseconds.
+type histogramJSON struct {
+	Count   int64         `json:"count"`
+	Sum     time.Duration `json:"sum"`
+	Min     time.Duration `json:"min"`
+	Max     time.Duration `json:"max"`
+	Buckets []Bucket      `json:"buckets"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	buckets := h.Buckets()
+	if buckets == nil {
+		buckets = []Bucket{}
+	}
+
+	return json.Marshal(histogramJSON{
+		Count:   h.total,
+		Sum:     h.sum,
+		Min:     h.min,
+		Max:     h.max,
+		Buckets: buckets,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (h *Histogram) UnmarshalJSON(b []byte) error {
+	var v histogramJSON
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+
+	*h = Histogram{
+		total: v.Count,
+		sum:   v.Sum,
+		min:   v.Min,
+		max:   v.Max,
+	}
+	for _, bucket := range v.Buckets {
+		if bucket.Lower < 0 {
+			return fmt.Errorf("invalid bucket: %v", bucket.Lower)
+		}
+		i := bucketIndex(int64(bucket.Lower))
+		if i >= len(h.counts) {
+			counts := make([]int64, i+1)
+			copy(counts, h.counts)
+			h.counts = counts
+		}
+		h.counts[i] += bucket.Count
+	}
+
+	return nil
+}
+
+// percentiles are the percentiles printed by WritePercentiles.
+var percentiles = []float64{0, 50, 75, 90, 95, 99, 99.9, 99.99, 100}
+
+// WritePercentiles writes a table of the percentile distribution of the
+// recorded values to w.
+func (h *Histogram) WritePercentiles(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%10s %14s %12s\n", "Percentile", "Value", "Count")
+	if err != nil {
+		return err
+	}
+
+	for _, p := range percentiles {
+		v := h.Quantile(p / 100)
+		_, err = fmt.Fprintf(w, "%10s %14s %12d\n", fmt.Sprintf("%g", p), v, h.countBelow(v))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "Count: %d, min: %s, mean: %s, max: %s\n", h.total, h.min, h.Mean(), h.max)
+	return err
+}
+
+// countBelow returns the number of recorded values in buckets up to and
+// including the bucket of d.
+func (h *Histogram) countBelow(d time.Duration) int64 {
+	last := bucketIndex(int64(d))
+	var n int64
+	for i := 0; i <= last && i < len(h.counts); i++ {
+		n += h.counts[i]
+	}
+	return n
+}
@@ -0,0 +1,88 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketBounds(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, 257, 1000, 123456789, 1 << 40} {
+		lower, upper := bucketBounds(bucketIndex(v))
+		assert.True(t, lower <= v, "%d", v)
+		assert.True(t, v < upper, "%d", v)
+		assert.True(t, upper-lower <= 1+v/subBuckets, "%d", v)
+	}
+
+	// Buckets are contiguous.
+	for i := 1; i < 10*subBuckets; i++ {
+		_, upper := bucketBounds(i - 1)
+		lower, _ := bucketBounds(i)
+		assert.Equal(t, upper, lower, "%d", i)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	assert.Equal(t, int64(1000), h.Count())
+	assert.Equal(t, time.Millisecond, h.Min())
+	assert.Equal(t, time.Second, h.Max())
+	assert.Equal(t, 500500*time.Microsecond, h.Mean())
+	assert.Equal(t, time.Millisecond, h.Quantile(0))
+	assert.Equal(t, time.Second, h.Quantile(1))
+
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		exact := time.Duration(q*1000) * time.Millisecond
+		assert.InEpsilon(t, float64(exact), float64(h.Quantile(q)), 1.0/subBuckets)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	h, h2 := NewHistogram(), NewHistogram()
+	h.Record(2 * time.Millisecond)
+	h2.Record(time.Millisecond)
+	h2.Record(time.Second)
+
+	h.Merge(h2)
+	assert.Equal(t, int64(3), h.Count())
+	assert.Equal(t, time.Millisecond, h.Min())
+	assert.Equal(t, time.Second, h.Max())
+}
+
+func TestHistogramJSON(t *testing.T) {
+	h := NewHistogram()
+	for i := 0; i < 100; i++ {
+		h.Record(time.Duration(i*i) * time.Microsecond)
+	}
+
+	b, err := json.Marshal(h)
+	require.Nil(t, err)
+
+	h2 := NewHistogram()
+	err = json.Unmarshal(b, h2)
+	require.Nil(t, err)
+	assert.Equal(t, h.Buckets(), h2.Buckets())
+	assert.Equal(t, h.Count(), h2.Count())
+	assert.Equal(t, h.Mean(), h2.Mean())
+	assert.Equal(t, h.Quantile(0.99), h2.Quantile(0.99))
+}
+
+func TestHistogramWritePercentiles(t *testing.T) {
+	h := NewHistogram()
+	h.Record(time.Millisecond)
+
+	buf := &bytes.Buffer{}
+	err := h.WritePercentiles(buf)
+	require.Nil(t, err)
+	assert.Contains(t, buf.String(), "Percentile")
+	assert.Contains(t, buf.String(), "99.99")
+	assert.Contains(t, buf.String(), "Count: 1, min: 1ms, mean: 1ms, max: 1ms")
+}
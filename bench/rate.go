@@ -0,0 +1,226 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// A Profile describes the request rate of an open-loop load test over time.
+type Profile interface {
+	// Rate returns the number of requests per second to send at the given
+	// time since the start of the load test.
+	Rate(elapsed time.Duration) float64
+}
+
+// Constant is a Profile with a constant rate, in requests per second.
+type Constant float64
+
+// Rate implements Profile.
+func (c Constant) Rate(time.Duration) float64 {
+	return float64(c)
+}
+
+func (c Constant) String() string {
+	return fmt.Sprintf("constant %g/s", float64(c))
+}
+
+// Step is a Profile that switches from one rate to another at a fixed time.
+type Step struct {
+	From float64
+	To   float64
+	At   time.Duration
+}
+
+// Rate implements Profile.
+func (s Step) Rate(elapsed time.Duration) float64 {
+	if elapsed < s.At {
+		return s.From
+	}
+	return s.To
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("step %g/s to %g/s at %s", s.From, s.To, s.At)
+}
+
+// Linear is a Profile that ramps from one rate to another linearly, and stays
+// at the final rate afterwards.
+type Linear struct {
+	From float64
+	To   float64
+	Over time.Duration
+}
+
+// Rate implements Profile.
+func (l Linear) Rate(elapsed time.Duration) float64 {
+	if elapsed >= l.Over {
+		return l.To
+	}
+	return l.From + (l.To-l.From)*float64(elapsed)/float64(l.Over)
+}
+
+func (l Linear) String() string {
+	return fmt.Sprintf("linear %g/s to %g/s over %s", l.From, l.To, l.Over)
+}
+
+// Spike is a Profile with a base rate that spikes to a peak rate for a while.
+type Spike struct {
+	Base   float64
+	Peak   float64
+	At     time.Duration
+	Length time.Duration
+}
+
+// Rate implements Profile.
+func (s Spike) Rate(elapsed time.Duration) float64 {
+	if elapsed >= s.At && elapsed < s.At+s.Length {
+		return s.Peak
+	}
+	return s.Base
+}
+
+func (s Spike) String() string {
+	return fmt.Sprintf("spike %g/s to %g/s at %s for %s", s.Base, s.Peak, s.At, s.Length)
+}
+
+// idleCheckInterval is the interval at which a Profile with a rate of zero is
+// checked again.
+const idleCheckInterval = 10 * time.Millisecond
+
+// RunRate runs an open-loop load test: Announces are scheduled according to
+// the Profile, regardless of how fast the tracker answers.
+// newAnnouncer is called once per worker. Every announce uses an idle
+// announcer, waiting for one to become idle if necessary.
+//
+// Latencies are measured from the time an announce was scheduled to be sent,
+// so time spent waiting for an idle announcer or behind schedule is included.
+// This avoids coordinated omission: A stalling tracker shows up in the
+// latencies of all announces that should have been sent during the stall.
+//
+// Announces are scheduled until the configured duration or number of
+// requests is reached, or ctx is done. Announces in flight at that point are
+// completed and recorded.
+func RunRate(ctx context.Context, newAnnouncer func() (poke.Announcer, error), cfg Config, p Profile) (*Result, error) {
+	if p == nil {
+		return nil, errors.New("load test needs a profile")
+	}
+
+	b, err := newBench(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	announcers, err := b.newAnnouncers(newAnnouncer)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAnnouncers(announcers)
+	idle := make(chan poke.Announcer, len(announcers))
+	for _, a := range announcers {
+		idle <- a
+	}
+
+	scheduleCtx := ctx
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		scheduleCtx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sessions []*session
+	)
+
+	start := time.Now()
+	intended := start
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		rate := p.Rate(intended.Sub(start))
+		if rate <= 0 {
+			intended = intended.Add(idleCheckInterval)
+			if !sleepUntil(scheduleCtx, timer, intended) {
+				break
+			}
+			continue
+		}
+
+		if !sleepUntil(scheduleCtx, timer, intended) || !b.claim() {
+			break
+		}
+
+		mu.Lock()
+		var s *session
+		if len(sessions) > 0 {
+			s = sessions[len(sessions)-1]
+			sessions = sessions[:len(sessions)-1]
+		} else {
+			s = b.newSession()
+		}
+		mu.Unlock()
+		req, _ := s.next()
+
+		wg.Add(1)
+		go func(s *session, req poke.AnnounceRequest, intended time.Time) {
+			defer wg.Done()
+
+			var a poke.Announcer
+			select {
+			case a = <-idle:
+			case <-ctx.Done():
+				return
+			}
+
+			resp, err := announce(ctx, a, req)
+			idle <- a
+			if err != nil && ctx.Err() != nil {
+				return
+			}
+			b.rec.record(time.Since(intended), resp, err)
+
+			if len(s.events) > 0 {
+				mu.Lock()
+				sessions = append(sessions, s)
+				mu.Unlock()
+			}
+		}(s, req, intended)
+
+		intended = intended.Add(time.Duration(float64(time.Second) / rate))
+	}
+
+	wg.Wait()
+
+	return b.rec.result(time.Since(start)), nil
+}
+
+// sleepUntil waits until t, reporting false if ctx is done first.
+func sleepUntil(ctx context.Context, timer *time.Timer, t time.Time) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	d := time.Until(t)
+	if d <= 0 {
+		return true
+	}
+
+	timer.Reset(d)
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		if !timer.Stop() {
+			<-timer.C
+		}
+		return false
+	}
+}
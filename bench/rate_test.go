@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/tracker"
+)
+
+func TestProfiles(t *testing.T) {
+	assert.Equal(t, 10.0, Constant(10).Rate(time.Hour))
+
+	step := Step{From: 10, To: 20, At: time.Second}
+	assert.Equal(t, 10.0, step.Rate(0))
+	assert.Equal(t, 20.0, step.Rate(time.Second))
+
+	linear := Linear{From: 10, To: 20, Over: time.Second}
+	assert.Equal(t, 10.0, linear.Rate(0))
+	assert.Equal(t, 15.0, linear.Rate(500*time.Millisecond))
+	assert.Equal(t, 20.0, linear.Rate(time.Hour))
+
+	spike := Spike{Base: 10, Peak: 100, At: time.Second, Length: time.Second}
+	assert.Equal(t, 10.0, spike.Rate(0))
+	assert.Equal(t, 100.0, spike.Rate(1500*time.Millisecond))
+	assert.Equal(t, 10.0, spike.Rate(2*time.Second))
+}
+
+func TestRunRate(t *testing.T) {
+	tr := tracker.New(tracker.DefaultConfig())
+	cfg := DefaultConfig()
+	cfg.Duration = 0
+	cfg.Requests = 50
+
+	start := time.Now()
+	res, err := RunRate(context.Background(), func() (poke.Announcer, error) { return tr, nil }, cfg, Constant(500))
+	require.Nil(t, err)
+
+	// 50 requests at 500/s take about 100ms.
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+	assert.Equal(t, 50, res.Requests)
+	assert.Equal(t, 0, res.Errors)
+	assert.Equal(t, int64(50), res.Histogram.Count())
+}
+
+// stallingAnnouncer stalls the first announce.
+type stallingAnnouncer struct {
+	once  sync.Once
+	stall time.Duration
+}
+
+func (a *stallingAnnouncer) Announce(poke.AnnounceRequest) (poke.OptionalAnnounceResponse, error) {
+	a.once.Do(func() { time.Sleep(a.stall) })
+	return poke.AnnounceResponse{}, nil
+}
+
+func TestRunRateStall(t *testing.T) {
+	a := &stallingAnnouncer{stall: 100 * time.Millisecond}
+	cfg := DefaultConfig()
+	cfg.Workers = 1
+	cfg.Duration = 0
+	cfg.Requests = 20
+
+	res, err := RunRate(context.Background(), func() (poke.Announcer, error) { return a, nil }, cfg, Constant(200))
+	require.Nil(t, err)
+	assert.Equal(t, 20, res.Requests)
+
+	// All announces scheduled during the stall wait for it, so at least half
+	// of them must show a large latency.
+	assert.True(t, res.Latencies.P50 >= 10*time.Millisecond, "p50 %s", res.Latencies.P50)
+	assert.True(t, res.Latencies.Max >= 100*time.Millisecond, "max %s", res.Latencies.Max)
+}
+
+func TestRunRateNoProfile(t *testing.T) {
+	_, err := RunRate(context.Background(), func() (poke.Announcer, error) { return failingAnnouncer{}, nil }, DefaultConfig(), nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,145 @@
+// Package capture records the raw traffic exchanged with a tracker and
+// replays it.
+//
+// Recording and replaying works on the level of poke.RoundTrippers, which the
+// HTTP and UDP clients use to exchange requests and responses with a tracker.
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// Protocols of captured traffic.
+const (
+	ProtocolHTTP = "http"
+	ProtocolUDP  = "udp"
+)
+
+// Exchange is a single request and its response, as exchanged with a tracker.
+//
+// Durations are encoded as nanoseconds in JSON, byte slices as base64.
+type Exchange struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+
+	// URL is the requested URL, for HTTP.
+	URL string `json:"url,omitempty"`
+
+	// Request is the request datagram, for UDP.
+	Request []byte `json:"request,omitempty"`
+
+	// Response is the response body for HTTP, or the response datagram for
+	// UDP.
+	Response []byte `json:"response,omitempty"`
+
+	// Err is the error that occurred instead of receiving a response, if any.
+	Err string `json:"error,omitempty"`
+
+	// Timeout indicates that Err is a timeout.
+	Timeout bool `json:"timeout,omitempty"`
+}
+
+// request returns the raw request of the exchange.
+func (e Exchange) request(protocol string) []byte {
+	if protocol == ProtocolHTTP {
+		return []byte(e.URL)
+	}
+	return e.Request
+}
+
+// Capture is the traffic exchanged with a tracker.
+type Capture struct {
+	PokeVersion string `json:"pokeVersion"`
+	Tracker     string `json:"tracker"`
+	Protocol    string `json:"protocol"`
+
+	// Seed is the seed of the Generator used to generate the requests.
+	Seed int64 `json:"seed"`
+
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// WriteCapture writes the Capture to w as JSON.
+func WriteCapture(w io.Writer, c Capture) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// ReadCapture reads a Capture written by WriteCapture from r.
+func ReadCapture(r io.Reader) (Capture, error) {
+	var c Capture
+	err := json.NewDecoder(r).Decode(&c)
+	if err != nil {
+		return Capture{}, poke.WrapError("unable to decode capture", err)
+	}
+	return c, nil
+}
+
+// Recorder records the traffic exchanged by RoundTrippers.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	capture Capture
+}
+
+// NewRecorder creates a new Recorder for traffic exchanged with the given
+// tracker using the given protocol.
+func NewRecorder(tracker, protocol string) *Recorder {
+	return &Recorder{
+		capture: Capture{
+			PokeVersion: poke.Version,
+			Tracker:     tracker,
+			Protocol:    protocol,
+			Exchanges:   make([]Exchange, 0),
+		},
+	}
+}
+
+// Wrap returns a RoundTripper that records all exchanges made via rt.
+func (r *Recorder) Wrap(rt poke.RoundTripper) poke.RoundTripper {
+	return poke.RoundTripperFunc(func(ctx context.Context, request []byte) ([]byte, error) {
+		start := time.Now()
+		resp, err := rt.RoundTrip(ctx, request)
+
+		e := Exchange{
+			Time:     start,
+			Duration: time.Since(start),
+			Response: append([]byte(nil), resp...),
+		}
+		if r.capture.Protocol == ProtocolHTTP {
+			e.URL = string(request)
+		} else {
+			e.Request = append([]byte(nil), request...)
+		}
+		if err != nil {
+			e.Err = err.Error()
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				e.Timeout = true
+			}
+		}
+
+		r.mu.Lock()
+		r.capture.Exchanges = append(r.capture.Exchanges, e)
+		r.mu.Unlock()
+
+		return resp, err
+	})
+}
+
+// Capture returns the traffic recorded so far.
+func (r *Recorder) Capture() Capture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.capture
+	c.Exchanges = append([]Exchange(nil), r.capture.Exchanges...)
+	return c
+}
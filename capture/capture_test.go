@@ -0,0 +1,171 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+	"github.com/mrd0ll4r/poke/tests"
+	"github.com/mrd0ll4r/poke/tracker"
+)
+
+// roundTrip writes and reads back the Capture.
+func roundTrip(t *testing.T, c Capture) Capture {
+	buf := bytes.NewBuffer(nil)
+	err := WriteCapture(buf, c)
+	require.Nil(t, err)
+
+	c, err = ReadCapture(buf)
+	require.Nil(t, err)
+	return c
+}
+
+func testConfig(seed int64, transport func(poke.RoundTripper) poke.RoundTripper) tests.Config {
+	return tests.Config{
+		Timeout:   time.Second,
+		Generator: poke.NewGenerator(seed),
+		Transport: transport,
+	}
+}
+
+func TestRecordReplayHTTP(t *testing.T) {
+	srv := httptest.NewServer(tracker.New(tracker.DefaultConfig()))
+	announceURI := srv.URL + "/announce"
+
+	rec := NewRecorder(announceURI, ProtocolHTTP)
+	res, err := tests.TestHTTPTracker(announceURI, testConfig(42, rec.Wrap))
+	srv.Close()
+	require.Nil(t, err)
+
+	c := roundTrip(t, rec.Capture())
+	assert.Equal(t, poke.Version, c.PokeVersion)
+	assert.Equal(t, announceURI, c.Tracker)
+	require.NotEmpty(t, c.Exchanges)
+	assert.NotEmpty(t, c.Exchanges[0].URL)
+	assert.NotEmpty(t, c.Exchanges[0].Response)
+
+	// The tracker is gone, all responses come from the capture.
+	r := NewReplayer(c)
+	replayed, err := tests.TestHTTPTracker(announceURI, testConfig(42, r.Transport))
+	require.Nil(t, err)
+	assert.Equal(t, 0, r.Remaining())
+	assert.Equal(t, len(res.Tests), len(replayed.Tests))
+	assert.Equal(t, res.SupportsCompact, replayed.SupportsCompact)
+	assert.Equal(t, res.SupportsScrape, replayed.SupportsScrape)
+	for i := range res.Tests {
+		assert.Equal(t, res.Tests[i].Result.Err, replayed.Tests[i].Result.Err, res.Tests[i].Name)
+	}
+
+	// Different requests are not recorded.
+	r = NewReplayer(c)
+	replayed, err = tests.TestHTTPTracker(announceURI, testConfig(43, r.Transport))
+	require.Nil(t, err)
+	assert.False(t, replayed.SupportsCompact)
+}
+
+func TestRecordReplayUDP(t *testing.T) {
+	conn, err := tracker.New(tracker.DefaultConfig()).ListenUDP("127.0.0.1:0")
+	require.Nil(t, err)
+	addr := conn.LocalAddr().String()
+
+	rec := NewRecorder(addr, ProtocolUDP)
+	res, err := tests.TestUDPTracker(addr, testConfig(42, rec.Wrap))
+	conn.Close()
+	require.Nil(t, err)
+
+	c := roundTrip(t, rec.Capture())
+	require.NotEmpty(t, c.Exchanges)
+	assert.Equal(t, 16, len(c.Exchanges[0].Request))
+
+	r := NewReplayer(c)
+	replayed, err := tests.TestUDPTracker(addr, testConfig(42, r.Transport))
+	require.Nil(t, err)
+	assert.Equal(t, 0, r.Remaining())
+	assert.Equal(t, res.TrackerResult.SupportsIPSpoofing, replayed.TrackerResult.SupportsIPSpoofing)
+	assert.Equal(t, res.TrackerResult.SupportsScrape, replayed.TrackerResult.SupportsScrape)
+	for i := range res.Tests {
+		assert.Equal(t, res.Tests[i].Result.Err, replayed.Tests[i].Result.Err, res.Tests[i].Name)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	c := Capture{
+		Protocol: ProtocolHTTP,
+		Exchanges: []Exchange{
+			{URL: "a", Response: []byte("1")},
+			{URL: "b", Err: "i/o timeout", Timeout: true},
+			{URL: "a", Response: []byte("2")},
+		},
+	}
+	r := NewReplayer(c)
+
+	b, err := r.RoundTrip(context.Background(), []byte("a"))
+	require.Nil(t, err)
+	assert.Equal(t, "1", string(b))
+
+	b, err = r.RoundTrip(context.Background(), []byte("a"))
+	require.Nil(t, err)
+	assert.Equal(t, "2", string(b))
+
+	_, err = r.RoundTrip(context.Background(), []byte("a"))
+	assert.Equal(t, ErrNotRecorded, err)
+
+	_, err = r.RoundTrip(context.Background(), []byte("b"))
+	require.NotNil(t, err)
+	assert.Equal(t, "i/o timeout", err.Error())
+	assert.True(t, err.(recordedError).Timeout())
+	assert.Equal(t, 0, r.Remaining())
+}
+
+func TestNewAnnouncer(t *testing.T) {
+	srv := httptest.NewServer(tracker.New(tracker.DefaultConfig()))
+	announceURI := srv.URL + "/announce"
+
+	g := poke.NewGenerator(0)
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     g.Peer(),
+		Event:    poke.EventStarted,
+		Left:     100,
+	}
+
+	rec := NewRecorder(announceURI, ProtocolHTTP)
+	c, err := http.NewClient(announceURI)
+	require.Nil(t, err)
+	c.SetRoundTripper(rec.Wrap(c.RoundTripper()))
+	resp, err := c.Announce(req)
+	srv.Close()
+	require.Nil(t, err)
+
+	a, err := NewAnnouncer(rec.Capture())
+	require.Nil(t, err)
+	replayed, err := a.Announce(req)
+	require.Nil(t, err)
+	assert.Equal(t, resp, replayed)
+
+	_, err = a.Announce(req)
+	assert.NotNil(t, err)
+}
+
+func TestReplayerUDPTransactionID(t *testing.T) {
+	request := []byte{0, 0, 4, 0x17, 0x27, 0x10, 0x19, 0x80, 0, 0, 0, 0, 1, 2, 3, 4}
+	response := []byte{0, 0, 0, 0, 1, 2, 3, 4, 8, 7, 6, 5, 4, 3, 2, 1}
+	r := NewReplayer(Capture{
+		Protocol:  ProtocolUDP,
+		Exchanges: []Exchange{{Request: request, Response: response}},
+	})
+
+	other := append([]byte(nil), request...)
+	copy(other[12:16], []byte{5, 6, 7, 8})
+	b, err := r.RoundTrip(context.Background(), other)
+	require.Nil(t, err)
+	assert.Equal(t, []byte{5, 6, 7, 8}, b[4:8])
+	assert.Equal(t, response[8:], b[8:])
+}
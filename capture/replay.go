@@ -0,0 +1,138 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+	"github.com/mrd0ll4r/poke/udp"
+)
+
+// ErrNotRecorded is returned by a Replayer for requests that were not
+// recorded.
+var ErrNotRecorded = errors.New("no recorded response for request")
+
+// recordedError is a replayed error.
+// It implements net.Error, so replayed timeouts are reported as timeouts.
+type recordedError struct {
+	msg     string
+	timeout bool
+}
+
+func (e recordedError) Error() string   { return e.msg }
+func (e recordedError) Timeout() bool   { return e.timeout }
+func (e recordedError) Temporary() bool { return e.timeout }
+
+// Replayer is a poke.RoundTripper that serves recorded responses.
+//
+// Every request is answered with the response of the first exchange with an
+// equal request that was not replayed yet. UDP transaction IDs are ignored
+// when comparing requests, the transaction ID of the response is set to
+// the transaction ID of the request.
+//
+// It is safe for concurrent use.
+type Replayer struct {
+	mu       sync.Mutex
+	capture  Capture
+	replayed []bool
+}
+
+var _ poke.RoundTripper = &Replayer{}
+
+// NewReplayer creates a new Replayer for the given Capture.
+func NewReplayer(c Capture) *Replayer {
+	return &Replayer{
+		capture:  c,
+		replayed: make([]bool, len(c.Exchanges)),
+	}
+}
+
+// RoundTrip implements poke.RoundTripper.
+// ErrNotRecorded is returned if no matching exchange is left.
+func (r *Replayer) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, e := range r.capture.Exchanges {
+		if r.replayed[i] || !r.matches(e.request(r.capture.Protocol), request) {
+			continue
+		}
+		r.replayed[i] = true
+
+		if e.Err != "" {
+			return nil, recordedError{msg: e.Err, timeout: e.Timeout}
+		}
+
+		resp := append([]byte(nil), e.Response...)
+		if r.capture.Protocol == ProtocolUDP && len(resp) >= 8 && len(request) >= 16 {
+			copy(resp[4:8], request[12:16])
+		}
+		return resp, nil
+	}
+
+	return nil, ErrNotRecorded
+}
+
+// Transport returns the Replayer, ignoring rt.
+// It can be used as the Transport of a tests.Config to replay a test run.
+func (r *Replayer) Transport(rt poke.RoundTripper) poke.RoundTripper {
+	return r
+}
+
+// Remaining returns the number of recorded exchanges that were not replayed.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for _, replayed := range r.replayed {
+		if !replayed {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Replayer) matches(recorded, request []byte) bool {
+	if r.capture.Protocol != ProtocolUDP || len(recorded) < 16 || len(request) < 16 {
+		return bytes.Equal(recorded, request)
+	}
+
+	// Compare everything but the transaction ID.
+	return len(recorded) == len(request) &&
+		bytes.Equal(recorded[:12], request[:12]) &&
+		bytes.Equal(recorded[16:], request[16:])
+}
+
+// NewAnnouncer creates a client for the tracker of the Capture, which serves
+// the recorded responses instead of talking to the tracker.
+// The client implements poke.Scraper as well.
+//
+// For UDP, creating the client may need to resolve the tracker's address.
+func NewAnnouncer(c Capture) (poke.Announcer, error) {
+	switch c.Protocol {
+	case ProtocolHTTP:
+		client, err := http.NewClient(c.Tracker)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRoundTripper(NewReplayer(c))
+		return client, nil
+	case ProtocolUDP:
+		client, err := udp.NewClient(c.Tracker)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRoundTripper(NewReplayer(c))
+		return client, nil
+	}
+
+	return nil, errors.New("unknown protocol: " + c.Protocol)
+}
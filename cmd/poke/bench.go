@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/bench"
+	"github.com/mrd0ll4r/poke/http"
+	"github.com/mrd0ll4r/poke/udp"
+)
+
+// runBench runs the bench subcommand with the given arguments.
+func runBench(args []string) {
+	cfg := bench.DefaultConfig()
+	var (
+		announceURI    string
+		udpAnnounceURI string
+		format         string
+		output         string
+		timeout        = udp.DefaultTimeout
+		retries        int
+		debug          bool
+		rate           float64
+		profile        string
+		peakRate       float64
+		rampAt         time.Duration
+		rampDuration   time.Duration
+	)
+
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	fs.StringVar(&announceURI, "a", "http://tracker.org:6881/announce", "the announce URI")
+	fs.StringVar(&udpAnnounceURI, "u", "", "the UDP announce URI, benchmarks UDP instead of HTTP if set")
+	fs.IntVar(&cfg.Workers, "workers", cfg.Workers, "the number of concurrent workers")
+	fs.IntVar(&cfg.InfoHashes, "infohashes", cfg.InfoHashes, "the number of infohashes to announce")
+	fs.DurationVar(&cfg.Duration, "duration", cfg.Duration, "the duration of the load test, 0 for no limit")
+	fs.IntVar(&cfg.Requests, "requests", cfg.Requests, "the number of announces to perform, 0 for no limit")
+	fs.IntVar(&cfg.Announces, "announces", cfg.Announces, "the number of regular announces per peer")
+	fs.IntVar(&cfg.Numwant, "numwant", cfg.Numwant, "the numwant to announce with")
+	fs.Float64Var(&rate, "rate", 0, "send announces at this many requests/s regardless of response times, instead of closed-loop")
+	fs.StringVar(&profile, "profile", "constant", "the rate profile, one of constant, step, linear, spike")
+	fs.Float64Var(&peakRate, "peak-rate", 0, "the rate the step, linear and spike profiles change to")
+	fs.DurationVar(&rampAt, "ramp-at", 0, "the time the step and spike profiles change the rate at")
+	fs.DurationVar(&rampDuration, "ramp-duration", 0, "the duration of the linear ramp or the spike")
+	fs.StringVar(&format, "format", "text", "the output format, one of text, json")
+	fs.StringVar(&output, "o", "", "the file to write the output to, defaults to stdout")
+	fs.DurationVar(&timeout, "timeout", timeout, "the time to wait for a response, doubled for every UDP retransmission")
+	fs.IntVar(&retries, "retries", 0, "the number of times UDP requests are retransmitted")
+	fs.BoolVar(&debug, "debug", false, "debug mode")
+	fs.Parse(args)
+
+	poke.Debug = debug
+
+	switch format {
+	case "text", "json":
+	default:
+		log.Fatalf("unknown format: %s", format)
+	}
+
+	protocol, tracker := "http", announceURI
+	newAnnouncer := func() (poke.Announcer, error) {
+		c, err := http.NewClient(announceURI)
+		if err != nil {
+			return nil, err
+		}
+		c.SetTimeout(timeout)
+		return c, nil
+	}
+	if udpAnnounceURI != "" {
+		protocol, tracker = "udp", udpAnnounceURI
+		newAnnouncer = func() (poke.Announcer, error) {
+			c, err := udp.NewClient(udpAnnounceURI)
+			if err != nil {
+				return nil, err
+			}
+			c.SetTimeout(timeout)
+			c.SetRetries(retries)
+			return c, nil
+		}
+	}
+
+	var res *bench.Result
+	var err error
+	if rate > 0 {
+		var p bench.Profile
+		switch profile {
+		case "constant":
+			p = bench.Constant(rate)
+		case "step":
+			p = bench.Step{From: rate, To: peakRate, At: rampAt}
+		case "linear":
+			p = bench.Linear{From: rate, To: peakRate, Over: rampDuration}
+		case "spike":
+			p = bench.Spike{Base: rate, Peak: peakRate, At: rampAt, Length: rampDuration}
+		default:
+			log.Fatalf("unknown profile: %s", profile)
+		}
+		res, err = bench.RunRate(context.Background(), newAnnouncer, cfg, p)
+	} else {
+		res, err = bench.Run(context.Background(), newAnnouncer, cfg)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Protocol = protocol
+	res.Tracker = tracker
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(res)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	formatBenchResult(w, res)
+}
+
+func formatBenchResult(w io.Writer, res *bench.Result) {
+	fmt.Fprintf(w, "Tracker: %s (%s)\n", res.Tracker, res.Protocol)
+	fmt.Fprintf(w, "Duration: %s\n", res.Duration)
+	fmt.Fprintf(w, "Requests: %d\n", res.Requests)
+	fmt.Fprintf(w, "Throughput: %.2f requests/s\n", res.Throughput)
+	fmt.Fprintf(w, "Errors: %d, timeouts: %d (%.2f%%)\n", res.Errors, res.Timeouts, 100*res.ErrorRate())
+	fmt.Fprintf(w, "Warnings: %d (%.2f%%)\n", res.Warnings, 100*res.WarningRate())
+	fmt.Fprintf(w, "Latency p50: %s\n", res.Latencies.P50)
+	fmt.Fprintf(w, "Latency p90: %s\n", res.Latencies.P90)
+	fmt.Fprintf(w, "Latency p99: %s\n", res.Latencies.P99)
+	fmt.Fprintf(w, "Latency max: %s\n", res.Latencies.Max)
+
+	fmt.Fprintln(w)
+	res.Histogram.WritePercentiles(w)
+}
@@ -1,137 +1,563 @@
 package main
 
 import (
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/capture"
+	"github.com/mrd0ll4r/poke/http"
+	"github.com/mrd0ll4r/poke/report"
 	"github.com/mrd0ll4r/poke/tests"
+	"github.com/mrd0ll4r/poke/udp"
 )
 
 func init() {
+	flag.Var(&trackerURLs, "t", "a tracker URL to test, http://, https:// or udp://, can be repeated")
 	flag.StringVar(&announceURI, "a", "http://tracker.org:6881/announce", "the announce URI")
-	flag.StringVar(&udpAnnounceURI, "u", "tracker.org:6881", "the UDP announce URI")
+	flag.StringVar(&udpAnnounceURI, "u", "tracker.org:6881", "the UDP tracker address, host:port or udp://host:port/path")
+	flag.BoolVar(&ipv6, "ipv6", false, "test the HTTP tracker using IPv6 peers")
+	flag.StringVar(&format, "format", "text", "the output format, one of text, json, junit, tap")
+	flag.StringVar(&output, "o", "", "the file to write the output to, defaults to stdout")
+	flag.DurationVar(&timeout, "timeout", udp.DefaultTimeout, "the time to wait for a response, doubled for every UDP retransmission, 0 to wait without a limit")
+	flag.IntVar(&retries, "retries", 0, "the number of times UDP requests are retransmitted")
+	flag.DurationVar(&connectionIDExpiry, "connection-id-expiry", 0, "the time to wait for a UDP connection ID to expire, 0 to skip the check, "+tests.ConnectionIDExpiryBEP15.String()+" to check BEP 15 expiry")
+	flag.Int64Var(&seed, "seed", 0, "the seed for generating infohashes and peers, random if not set")
+	flag.StringVar(&record, "record", "", "the file to record the traffic exchanged with the tracker to")
+	flag.StringVar(&replay, "replay", "", "the file to replay recorded traffic from instead of contacting the tracker")
+	flag.StringVar(&runPattern, "run", "", "run only the tests whose names match this regular expression")
+	flag.StringVar(&tags, "tags", "", "run only the tests with one of these comma-separated tags: conformance, negative, capability, transport, tls, private")
+	flag.StringVar(&caFile, "ca", "", "the PEM file with the CA certificates to verify HTTPS trackers with, instead of the system's")
+	flag.StringVar(&certFile, "cert", "", "the PEM file with the client certificate for HTTPS trackers requiring mutual TLS")
+	flag.StringVar(&keyFile, "key", "", "the PEM file with the key of the client certificate")
+	flag.StringVar(&serverName, "sni", "", "the server name to send via SNI and verify HTTPS trackers' certificates against")
+	flag.BoolVar(&insecure, "insecure", false, "do not verify the certificates of HTTPS trackers")
+	flag.Var(&passkeys, "passkey", "a valid passkey of a private tracker, can be repeated, the tracker URL must contain the first one")
+	flag.Var(&invalidPasskeys, "invalid-passkey", "a passkey the private tracker must reject, can be repeated")
+	flag.Var(&infoHashes, "infohash", "the hex-encoded infohash of a torrent registered with the private tracker, can be repeated")
+	flag.StringVar(&contentTypes, "content-types", strings.Join(tests.DefaultHTTPExpectations().ContentTypes, ","), "the comma-separated content types allowed for HTTP responses, empty to allow any")
+	flag.BoolVar(&allowChunked, "allow-chunked", false, "allow chunked HTTP responses")
+	flag.BoolVar(&allowRedirects, "allow-redirects", false, "allow HTTP trackers to redirect announces and scrapes")
+	flag.IntVar(&failureStatus, "failure-status", tests.DefaultHTTPExpectations().FailureStatusCode, "the HTTP status code expected for failure responses")
+	flag.BoolVar(&strict, "strict", false, "fail tests whose HTTP tracker responses are not canonical bencode")
+	flag.BoolVar(&list, "list", false, "list the selected tests instead of running them")
 	flag.BoolVar(&debug, "debug", false, "debug mode")
 }
 
 var (
-	announceURI    string
-	udpAnnounceURI string
-	debug          bool
+	trackerURLs        stringList
+	announceURI        string
+	udpAnnounceURI     string
+	ipv6               bool
+	format             string
+	output             string
+	timeout            time.Duration
+	retries            int
+	connectionIDExpiry time.Duration
+	seed               int64
+	record             string
+	replay             string
+	runPattern         string
+	tags               string
+	contentTypes       string
+	passkeys           stringList
+	invalidPasskeys    stringList
+	infoHashes         stringList
+	caFile             string
+	certFile           string
+	keyFile            string
+	serverName         string
+	insecure           bool
+	allowChunked       bool
+	allowRedirects     bool
+	failureStatus      int
+	strict             bool
+	list               bool
+	debug              bool
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 
 	poke.Debug = debug
 
+	switch format {
+	case "text", "json", "junit", "tap":
+	default:
+		log.Fatalf("unknown format: %s", format)
+	}
+
+	if record != "" && replay != "" {
+		log.Fatal("-record and -replay are mutually exclusive")
+	}
+
+	cfg := config()
+	if list {
+		listTests(os.Stdout, cfg)
+		return
+	}
+
+	urls := append([]string(trackerURLs), flag.Args()...)
+	if len(urls) > 0 {
+		runTrackers(urls, cfg)
+		return
+	}
+
+	tracker, protocol := announceURI, capture.ProtocolHTTP
 	if f := flag.Lookup("u"); f != nil && f.Value.String() != f.DefValue {
-		runUDPTests(udpAnnounceURI)
-	} else {
-		runHTTPTests(announceURI)
+		tracker, protocol = udpAnnounceURI, capture.ProtocolUDP
+	}
+
+	if replay != "" {
+		c, err := readCapture(replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tracker, protocol = c.Tracker, c.Protocol
+		cfg.Generator = poke.NewGenerator(c.Seed)
+		cfg.Transport = capture.NewReplayer(c).Transport
 	}
-}
 
-func runUDPTests(addr string) {
-	res, err := tests.TestUDPTracker(addr)
+	var rec *capture.Recorder
+	if record != "" {
+		rec = capture.NewRecorder(tracker, protocol)
+		cfg.Transport = rec.Wrap
+	}
+
+	r, err := runTests(target{tracker, protocol}, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	formatTrackerResult(res.TrackerResult)
-}
+	if rec != nil {
+		c := rec.Capture()
+		c.Seed = r.TrackerResult().Seed
+		err := writeCapture(record, c)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-func runHTTPTests(announceURI string) {
-	res, err := tests.TestHTTPTracker(announceURI)
+	err = writeReports([]report.Report{r})
 	if err != nil {
 		log.Fatal(err)
 	}
+}
+
+// stringList is a flag.Value that collects the values of a repeated flag.
+type stringList []string
 
-	fmt.Printf("Tracker supports HTTP compact announces: %t\n", res.SupportsCompact)
-	fmt.Printf("Tracker supports HTTP non-compact announces: %t\n", res.SupportsNonCompact)
-	formatTrackerResult(res.TrackerResult)
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
 }
 
-func formatTrackerResult(res tests.TrackerResult) {
-	fmt.Printf("Tracker supports IP spoofing: %t\n", res.SupportsIPSpoofing)
-	fmt.Printf("Tracker supports optimized announce responses: %t\n", res.SupportsAnnouncingPeerNotInPeerList)
-	fmt.Printf("Tracker supports optimized seeder announce responses: %t\n", res.SupportsOptimizedSeederResponse)
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
 
-	fmt.Println()
-	fmt.Println("Poke ran these tests:")
-	for _, t := range res.Tests {
-		if !t.Run {
-			continue
-		}
-		fmt.Println()
-		fmt.Printf("Test: %s\n", t.Name)
-		if t.Result.Result != nil {
-			fmt.Printf("Result: %v\n", t.Result.Result)
-		}
-		if t.Result.Err != nil {
-			fmt.Printf("Error: %s\n", t.Result.Err)
+// A target is a tracker and the protocol to test it with.
+type target struct {
+	tracker  string
+	protocol string
+}
+
+// parseTarget determines the protocol of a tracker from its URL scheme.
+func parseTarget(s string) (target, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return target{}, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return target{s, capture.ProtocolHTTP}, nil
+	case "udp":
+		return target{s, capture.ProtocolUDP}, nil
+	}
+
+	return target{}, fmt.Errorf("unsupported tracker URL: %s", s)
+}
+
+// runTrackers tests the trackers concurrently and writes the reports of all
+// trackers that could be tested.
+// Poke exits with a non-zero status if any tracker could not be tested.
+func runTrackers(urls []string, cfg tests.Config) {
+	targets := make([]target, 0, len(urls))
+	for _, u := range urls {
+		t, err := parseTarget(u)
+		if err != nil {
+			log.Fatal(err)
 		}
+		targets = append(targets, t)
 	}
 
-	fmt.Println()
-	fmt.Println("Poke did not run these tests:")
-	for _, t := range res.Tests {
-		if t.Run {
+	if replay != "" {
+		log.Fatal("-replay cannot be combined with tracker URLs")
+	}
+	if record != "" && len(targets) > 1 {
+		log.Fatal("-record can only be used with a single tracker")
+	}
+
+	var rec *capture.Recorder
+	if record != "" {
+		rec = capture.NewRecorder(targets[0].tracker, targets[0].protocol)
+		cfg.Transport = rec.Wrap
+	}
+
+	var (
+		wg      sync.WaitGroup
+		reports = make([]report.Report, len(targets))
+		errs    = make([]error, len(targets))
+	)
+	for i, t := range targets {
+		// Every tracker gets its own Generator, so that each tracker
+		// sees the same infohashes and peers for the same seed.
+		c := cfg
+		c.Generator = poke.NewGenerator(cfg.Generator.Seed())
+
+		wg.Add(1)
+		go func(i int, t target, c tests.Config) {
+			defer wg.Done()
+			reports[i], errs[i] = runTests(t, c)
+		}(i, t, c)
+	}
+	wg.Wait()
+
+	var rs []report.Report
+	for i, t := range targets {
+		if errs[i] != nil {
+			log.Printf("unable to test %s: %s", t.tracker, errs[i])
 			continue
 		}
-		fmt.Printf("%s\t- %s\n", t.Name, t.NotRunReason)
+		rs = append(rs, reports[i])
 	}
-}
 
-func runOtherTests(announceURI string) {
-	err := tests.BasicHTTPSeederAnnounce(announceURI)
-	if err != nil {
-		log.Println(err)
+	if rec != nil && len(rs) == 1 {
+		c := rec.Capture()
+		c.Seed = rs[0].TrackerResult().Seed
+		err := writeCapture(record, c)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	err = tests.CheckReturnedPeersHTTPAnnounce(announceURI)
+	err := writeReports(rs)
 	if err != nil {
-		log.Println(err)
+		log.Fatal(err)
 	}
 
-	err = tests.InvalidShortInfohashHTTPAnnounce(announceURI)
+	if len(rs) != len(targets) {
+		os.Exit(1)
+	}
+}
+
+func readCapture(name string) (capture.Capture, error) {
+	f, err := os.Open(name)
 	if err != nil {
-		log.Println(err)
+		return capture.Capture{}, err
 	}
+	defer f.Close()
+
+	return capture.ReadCapture(f)
+}
 
-	err = tests.InvalidLongInfohashHTTPAnnounce(announceURI)
+func writeCapture(name string, c capture.Capture) error {
+	f, err := os.Create(name)
 	if err != nil {
-		log.Println(err)
+		return err
 	}
 
-	err = tests.InvalidShortPeerIDHTTPAnnounce(announceURI)
+	err = capture.WriteCapture(f, c)
 	if err != nil {
-		log.Println(err)
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeReports(rs []report.Report) error {
+	if output == "" {
+		return formatReports(os.Stdout, rs)
 	}
 
-	err = tests.InvalidLongPeerIDHTTPAnnounce(announceURI)
+	f, err := os.Create(output)
 	if err != nil {
-		log.Println(err)
+		return err
 	}
 
-	err = tests.InvalidNegativeUploadedHTTPAnnounce(announceURI)
+	err = formatReports(f, rs)
 	if err != nil {
-		log.Println(err)
+		f.Close()
+		return err
 	}
+	return f.Close()
+}
 
-	err = tests.InvalidNegativeDownloadedHTTPAnnounce(announceURI)
+func formatReports(w io.Writer, rs []report.Report) error {
+	switch {
+	case format == "text" && len(rs) == 1:
+		formatReport(w, rs[0])
+		return nil
+	case format == "text":
+		for _, r := range rs {
+			fmt.Fprintf(w, "Tracker: %s (%s)\n", r.Tracker, r.Protocol)
+			formatReport(w, r)
+			fmt.Fprintln(w)
+		}
+		formatSummary(w, rs)
+		return nil
+	case format == "json" && len(rs) == 1:
+		return report.WriteJSON(w, rs[0])
+	case format == "json":
+		return report.WriteCombinedJSON(w, report.NewCombined(rs))
+	case format == "junit":
+		return report.WriteJUnit(w, rs...)
+	case format == "tap":
+		return report.WriteTAP(w, rs...)
+	}
+
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+func config() tests.Config {
+	s := time.Now().UnixNano()
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			s = seed
+		}
+	})
+
+	cfg := tests.Config{
+		Timeout:            timeout,
+		Retries:            retries,
+		ConnectionIDExpiry: connectionIDExpiry,
+		Generator:          poke.NewGenerator(s),
+		Strict:             strict,
+		HTTP: tests.HTTPExpectations{
+			AllowChunked:      allowChunked,
+			AllowRedirects:    allowRedirects,
+			FailureStatusCode: failureStatus,
+		},
+	}
+
+	if caFile != "" || certFile != "" || keyFile != "" || serverName != "" || insecure {
+		tlsConfig, err := http.TLSOptions{
+			CAFile:             caFile,
+			CertFile:           certFile,
+			KeyFile:            keyFile,
+			ServerName:         serverName,
+			InsecureSkipVerify: insecure,
+		}.TLSConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.TLS = tlsConfig
+	}
+
+	if len(passkeys) > 0 || len(invalidPasskeys) > 0 || len(infoHashes) > 0 {
+		cfg.Private = &tests.PrivateTracker{
+			Passkeys:        passkeys,
+			InvalidPasskeys: invalidPasskeys,
+		}
+		for _, s := range infoHashes {
+			ih, err := hex.DecodeString(s)
+			if err != nil || len(ih) != 20 {
+				log.Fatalf("invalid infohash: %s", s)
+			}
+			cfg.Private.InfoHashes = append(cfg.Private.InfoHashes, poke.InfoHash(ih))
+		}
+	}
+
+	if contentTypes != "" {
+		for _, t := range strings.Split(contentTypes, ",") {
+			cfg.HTTP.ContentTypes = append(cfg.HTTP.ContentTypes, strings.TrimSpace(t))
+		}
+	}
+
+	if runPattern != "" {
+		re, err := regexp.Compile(runPattern)
+		if err != nil {
+			log.Fatalf("invalid -run pattern: %s", err)
+		}
+		cfg.Run = re
+	}
+
+	if tags != "" {
+		for _, t := range strings.Split(tags, ",") {
+			switch tag := tests.Tag(strings.TrimSpace(t)); tag {
+			case tests.TagConformance, tests.TagNegative, tests.TagCapability, tests.TagTransport, tests.TagTLS, tests.TagPrivate:
+				cfg.Tags = append(cfg.Tags, tag)
+			default:
+				log.Fatalf("unknown tag: %s", tag)
+			}
+		}
+	}
+
+	return cfg
+}
+
+// listTests lists the tests selected by the Config.
+// Tests that are only run as prerequisites of the selected tests are not
+// listed.
+func listTests(w io.Writer, cfg tests.Config) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPROTOCOLS\tTAGS\tDEPENDS\tPRODUCES")
+	for _, d := range tests.Definitions() {
+		if !cfg.Selects(d) {
+			continue
+		}
+
+		var protocols, tags, depends, produces []string
+		for _, p := range d.Protocols {
+			protocols = append(protocols, string(p))
+		}
+		for _, t := range d.Tags {
+			tags = append(tags, string(t))
+		}
+		for _, c := range d.Depends {
+			depends = append(depends, string(c))
+		}
+		for _, c := range d.Produces {
+			produces = append(produces, string(c))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Name, strings.Join(protocols, ","), strings.Join(tags, ","),
+			listOrDash(depends), listOrDash(produces))
+	}
+	tw.Flush()
+}
+
+func listOrDash(s []string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return strings.Join(s, ",")
+}
+
+func runTests(t target, cfg tests.Config) (report.Report, error) {
+	switch {
+	case t.protocol == capture.ProtocolUDP:
+		return runUDPTests(t.tracker, cfg)
+	case ipv6:
+		return runHTTPIPv6Tests(t.tracker, cfg)
+	default:
+		return runHTTPTests(t.tracker, cfg)
+	}
+}
+
+func runUDPTests(addr string, cfg tests.Config) (report.Report, error) {
+	res, err := tests.TestUDPTracker(addr, cfg)
 	if err != nil {
-		log.Println(err)
+		return report.Report{}, err
 	}
 
-	err = tests.InvalidNegativeLeftHTTPAnnounce(announceURI)
+	return report.NewUDPReport(addr, res), nil
+}
+
+func runHTTPTests(announceURI string, cfg tests.Config) (report.Report, error) {
+	res, err := tests.TestHTTPTracker(announceURI, cfg)
 	if err != nil {
-		log.Println(err)
+		return report.Report{}, err
 	}
 
-	err = tests.InvalidEventHTTPAnnounce(announceURI)
+	return report.NewHTTPReport(announceURI, res), nil
+}
+
+func runHTTPIPv6Tests(announceURI string, cfg tests.Config) (report.Report, error) {
+	res, err := tests.TestHTTPTrackerIPv6(announceURI, cfg)
 	if err != nil {
-		log.Println(err)
+		return report.Report{}, err
+	}
+
+	return report.NewHTTPReport(announceURI, res), nil
+}
+
+// formatSummary writes a table with the number of passed, failed and not run
+// tests per tracker, followed by the totals.
+func formatSummary(w io.Writer, rs []report.Report) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TRACKER\tPROTOCOL\tPASSED\tFAILED\tNOT RUN\tDURATION")
+
+	var total report.Summary
+	for _, r := range rs {
+		s := r.Summary()
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", s.Tracker, s.Protocol, s.Passed, s.Failed, s.NotRun, s.Duration)
+		total.Passed += s.Passed
+		total.Failed += s.Failed
+		total.NotRun += s.NotRun
+		total.Duration += s.Duration
+	}
+
+	fmt.Fprintf(tw, "total\t\t%d\t%d\t%d\t%s\n", total.Passed, total.Failed, total.NotRun, total.Duration)
+	tw.Flush()
+}
+
+func formatReport(w io.Writer, r report.Report) {
+	fmt.Fprintf(w, "Seed: %d\n", r.TrackerResult().Seed)
+	if r.HTTP != nil {
+		fmt.Fprintf(w, "Tracker supports HTTP compact announces: %t\n", r.HTTP.SupportsCompact)
+		fmt.Fprintf(w, "Tracker supports HTTP non-compact announces: %t\n", r.HTTP.SupportsNonCompact)
+		fmt.Fprintf(w, "Tracker honours no_peer_id: %t\n", r.HTTP.HonoursNoPeerID)
+	}
+	if r.UDP != nil {
+		fmt.Fprintf(w, "Tracker rejects forged connection IDs: %t\n", r.UDP.RejectsForgedConnectionIDs)
+		fmt.Fprintf(w, "Tracker supports reusing connection IDs: %t\n", r.UDP.SupportsConnectionIDReuse)
+		fmt.Fprintf(w, "Tracker binds connection IDs to addresses: %t\n", r.UDP.BindsConnectionIDsToAddress)
+		fmt.Fprintf(w, "Tracker expires connection IDs: %t\n", r.UDP.ExpiresConnectionIDs)
+		fmt.Fprintf(w, "Tracker honours URL data: %t\n", r.UDP.HonoursURLData)
+	}
+	formatTrackerResult(w, r.TrackerResult())
+}
+
+func formatTrackerResult(w io.Writer, res tests.TrackerResult) {
+	fmt.Fprintf(w, "Tracker supports IP spoofing: %t\n", res.SupportsIPSpoofing)
+	fmt.Fprintf(w, "Tracker supports optimized announce responses: %t\n", res.SupportsAnnouncingPeerNotInPeerList)
+	fmt.Fprintf(w, "Tracker supports optimized seeder announce responses: %t\n", res.SupportsOptimizedSeederResponse)
+	fmt.Fprintf(w, "Tracker supports scrape: %t\n", res.SupportsScrape)
+	fmt.Fprintf(w, "Tracker supports IPv6: %t\n", res.SupportsIPv6)
+	fmt.Fprintf(w, "Tracker identifies peers by key: %t\n", res.IdentifiesPeersByKey)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Poke ran these tests:")
+	for _, t := range res.Tests {
+		if !t.Run {
+			continue
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Test: %s\n", t.Name)
+		if t.Result.Result != nil {
+			fmt.Fprintf(w, "Result: %v\n", t.Result.Result)
+		}
+		if t.Result.Err != nil {
+			fmt.Fprintf(w, "Error: %s\n", t.Result.Err)
+		}
+		for _, f := range t.Result.Findings {
+			fmt.Fprintf(w, "Finding: %s\n", f)
+		}
+		fmt.Fprintf(w, "Duration: %s\n", t.Duration)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Poke did not run these tests:")
+	for _, t := range res.Tests {
+		if t.Run {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t- %s\n", t.Name, t.NotRunReason)
 	}
 }
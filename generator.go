@@ -0,0 +1,244 @@
+package poke
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+)
+
+// DefaultPeerIDPrefix is the prefix of the IDs of peers generated by a
+// Generator, unless configured otherwise.
+const DefaultPeerIDPrefix = "-POKE64-0000000"
+
+// MaxPeerIDPrefixLength is the maximum length of a peer ID prefix.
+// The remaining bytes of a peer ID identify the peer, at least five are
+// needed to encode its port.
+const MaxPeerIDPrefixLength = 15
+
+// Port range used for generated peers.
+const (
+	minPeerPort  = 1024
+	numPeerPorts = 65536 - minPeerPort
+)
+
+// maxExtraHosts is the number of IPv4 addresses in 198.18.0.0/15, the range
+// reserved for benchmarks, used for the peers generated after the first
+// numPeerPorts peers.
+const maxExtraHosts = 1 << 17
+
+// ErrPeerIDPrefixTooLong is returned for peer ID prefixes longer than
+// MaxPeerIDPrefixLength.
+var ErrPeerIDPrefixTooLong = errors.New("peer ID prefix too long")
+
+// Generator generates unique, random infohashes and peers.
+//
+// Generated infohashes are unique among all infohashes generated by the
+// Generator. Generated peers have unique IDs and unique combinations of IP
+// address and port among all peers generated by the Generator.
+// The first 64,512 peers have unique ports and IP addresses as well, the
+// following peers share an IP address for every 64,512 peers.
+// A Generator produces the same sequence of values for the same seed.
+//
+// It is safe for concurrent use.
+type Generator struct {
+	mu         sync.Mutex
+	seed       int64
+	r          *rand.Rand
+	prefix     string
+	infoHashes map[[20]byte]struct{}
+
+	// host is the index of the IP address peers are generated for, ports
+	// are the ports used on it.
+	host  int
+	ports map[uint16]struct{}
+
+	// peers is the number of peers generated.
+	peers int
+}
+
+// NewGenerator creates a new Generator seeded with seed.
+func NewGenerator(seed int64) *Generator {
+	g := &Generator{
+		seed:   seed,
+		prefix: DefaultPeerIDPrefix,
+	}
+	g.reset()
+	return g
+}
+
+// Seed returns the seed of the Generator.
+func (g *Generator) Seed() int64 {
+	return g.seed
+}
+
+// SetPeerIDPrefix sets the prefix of the IDs of peers generated afterwards.
+// The rest of a peer ID is the peer's port, padded with zeros to 20 bytes.
+func (g *Generator) SetPeerIDPrefix(prefix string) error {
+	if len(prefix) > MaxPeerIDPrefixLength {
+		return ErrPeerIDPrefixTooLong
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prefix = prefix
+	return nil
+}
+
+// Reset resets the Generator to its initial state: It forgets all values
+// generated so far and starts over with its seed.
+// The peer ID prefix is kept.
+func (g *Generator) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.reset()
+}
+
+func (g *Generator) reset() {
+	g.r = rand.New(rand.NewSource(g.seed))
+	g.infoHashes = make(map[[20]byte]struct{})
+	g.host = 0
+	g.ports = make(map[uint16]struct{})
+	g.peers = 0
+}
+
+// InfoHash generates a unique, random InfoHash.
+func (g *Generator) InfoHash() InfoHash {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var infoHash [20]byte
+	for {
+		g.r.Read(infoHash[:])
+		if _, ok := g.infoHashes[infoHash]; !ok {
+			break
+		}
+	}
+	g.infoHashes[infoHash] = struct{}{}
+
+	return append(InfoHash(nil), infoHash[:]...)
+}
+
+// Uint32 generates a random uint32, for values that need not be unique, like
+// UDP transaction IDs.
+func (g *Generator) Uint32() uint32 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.r.Uint32()
+}
+
+// Key generates a random, non-zero key, which identifies a client across
+// changes of its IP address.
+func (g *Generator) Key() uint32 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for {
+		if k := g.r.Uint32(); k != 0 {
+			return k
+		}
+	}
+}
+
+// Peer generates a random, unique Peer with an IPv4 address.
+//
+// It panics if the Generator ran out of unique peers, see PeersLeft and Reset.
+func (g *Generator) Peer() Peer {
+	ip, port, id := g.peer()
+
+	return Peer{
+		Port: port,
+		IP:   net.IPv4(ip[0], ip[1], ip[2], ip[3]),
+		ID:   id,
+	}
+}
+
+// Peer6 generates a random, unique Peer with an IPv6 address in
+// 2001:db8::/96.
+// Peers generated by Peer6 are unique among the peers generated by Peer as
+// well.
+//
+// It panics if the Generator ran out of unique peers, see PeersLeft and Reset.
+func (g *Generator) Peer6() Peer {
+	ip, port, id := g.peer()
+
+	return Peer{
+		Port: port,
+		IP:   append(net.IP{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0}, ip[:]...),
+		ID:   id,
+	}
+}
+
+// PeersLeft returns the number of unique peers the Generator can still
+// generate with the current peer ID prefix.
+func (g *Generator) PeersLeft() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if left := g.maxPeers() - g.peers; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// maxPeers returns the number of unique peers that can be generated with the
+// current peer ID prefix.
+// The first numPeerPorts peer IDs end in the peer's port, the following ones
+// end in their index encoded in lowercase letters, which makes them distinct.
+func (g *Generator) maxPeers() int {
+	ids := 1
+	for i := len(g.prefix); i < 20 && ids < maxExtraHosts*numPeerPorts; i++ {
+		ids *= 26
+	}
+	extraHosts := ids / numPeerPorts
+	if extraHosts > maxExtraHosts {
+		extraHosts = maxExtraHosts
+	}
+	return (1 + extraHosts) * numPeerPorts
+}
+
+// peer generates a unique combination of IPv4 address and port and the peer
+// ID derived from it.
+func (g *Generator) peer() ([4]byte, uint16, string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.peers >= g.maxPeers() {
+		panic("generator ran out of unique peers")
+	}
+	if len(g.ports) >= numPeerPorts {
+		g.host++
+		g.ports = make(map[uint16]struct{})
+	}
+
+	// Probe linearly from a random port, so this terminates even if almost
+	// all ports are used.
+	i := g.r.Intn(numPeerPorts)
+	for {
+		if _, ok := g.ports[uint16(minPeerPort+i)]; !ok {
+			break
+		}
+		i = (i + 1) % numPeerPorts
+	}
+	port := uint16(minPeerPort + i)
+	g.ports[port] = struct{}{}
+	g.peers++
+
+	n := 20 - len(g.prefix)
+	if g.host == 0 {
+		return [4]byte{64, 64, byte(port & 0xFF), byte(port >> 8)}, port, g.prefix + fmt.Sprintf("%0*d", n, port)
+	}
+
+	h := g.host - 1
+	ip := [4]byte{198, 18 + byte(h>>16), byte(h >> 8), byte(h)}
+
+	id := make([]byte, n)
+	index := h*numPeerPorts + i
+	for j := n - 1; j >= 0; j-- {
+		id[j] = 'a' + byte(index%26)
+		index /= 26
+	}
+	return ip, port, g.prefix + string(id)
+}
@@ -0,0 +1,127 @@
+package poke
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorInfoHash(t *testing.T) {
+	g := NewGenerator(0)
+	ih := g.InfoHash()
+	assert.Equal(t, 20, len(ih))
+
+	ih2 := g.InfoHash()
+	assert.NotEqual(t, ih, ih2)
+}
+
+func TestGeneratorPeer(t *testing.T) {
+	g := NewGenerator(0)
+	peer := g.Peer()
+	assert.Equal(t, 20, len(peer.ID))
+	assert.True(t, peer.Port >= 1024)
+	assert.NotNil(t, peer.IP.To4())
+
+	peer2 := g.Peer()
+	assert.Equal(t, 20, len(peer2.ID))
+	assert.True(t, peer2.Port >= 1024)
+
+	assert.NotEqual(t, peer.Port, peer2.Port)
+	assert.NotEqual(t, peer.ID, peer2.ID)
+	assert.NotEqual(t, peer.IP, peer2.IP)
+}
+
+func TestGeneratorPeer6(t *testing.T) {
+	g := NewGenerator(0)
+	peer := g.Peer6()
+	assert.Equal(t, 20, len(peer.ID))
+	assert.Nil(t, peer.IP.To4())
+
+	peer2 := g.Peer()
+	assert.NotEqual(t, peer.Port, peer2.Port)
+	assert.NotEqual(t, peer.ID, peer2.ID)
+}
+
+func TestGeneratorAllPeers(t *testing.T) {
+	g := NewGenerator(0)
+	ports := make(map[uint16]struct{})
+	for i := 0; i < numPeerPorts; i++ {
+		p := g.Peer()
+		assert.True(t, p.Port >= 1024)
+		ports[p.Port] = struct{}{}
+	}
+	assert.Equal(t, numPeerPorts, len(ports))
+
+	left := g.PeersLeft()
+	assert.True(t, left > 10000000)
+
+	p := g.Peer()
+	assert.True(t, p.IP.Equal(net.IPv4(198, 18, 0, 0)))
+	assert.Equal(t, 20, len(p.ID))
+	assert.Equal(t, left-1, g.PeersLeft())
+
+	g.peers = g.maxPeers()
+	assert.Equal(t, 0, g.PeersLeft())
+	assert.Panics(t, func() { g.Peer() })
+
+	g.Reset()
+	assert.NotPanics(t, func() { g.Peer() })
+}
+
+func TestGeneratorManyPeers(t *testing.T) {
+	g := NewGenerator(0)
+	addrs := make(map[string]struct{})
+	ids := make(map[string]struct{})
+	n := 2*numPeerPorts + 10
+	for i := 0; i < n; i++ {
+		var p Peer
+		if i%2 == 0 {
+			p = g.Peer()
+		} else {
+			p = g.Peer6()
+		}
+		addrs[net.JoinHostPort(string(p.IP.To16()), strconv.Itoa(int(p.Port)))] = struct{}{}
+		ids[p.ID] = struct{}{}
+		assert.Equal(t, 20, len(p.ID))
+	}
+	assert.Equal(t, n, len(addrs))
+	assert.Equal(t, n, len(ids))
+
+	p := g.Peer()
+	_, reserved, _ := net.ParseCIDR("198.18.0.0/15")
+	assert.True(t, reserved.Contains(p.IP))
+
+	p = g.Peer6()
+	_, documentation, _ := net.ParseCIDR("2001:db8::/32")
+	assert.True(t, documentation.Contains(p.IP))
+}
+
+func TestGeneratorDeterministic(t *testing.T) {
+	g, g2 := NewGenerator(42), NewGenerator(42)
+	assert.Equal(t, g.InfoHash(), g2.InfoHash())
+	assert.True(t, g.Peer().IsReallyEqual(g2.Peer()))
+
+	ih := g.InfoHash()
+	g.Reset()
+	g.InfoHash()
+	g.Peer()
+	assert.Equal(t, ih, g.InfoHash())
+
+	assert.NotEqual(t, g.InfoHash(), NewGenerator(43).InfoHash())
+}
+
+func TestGeneratorPeerIDPrefix(t *testing.T) {
+	g := NewGenerator(0)
+	err := g.SetPeerIDPrefix("-qB4250-")
+	assert.Nil(t, err)
+
+	p := g.Peer()
+	assert.Equal(t, 20, len(p.ID))
+	assert.True(t, strings.HasPrefix(p.ID, "-qB4250-"))
+
+	err = g.SetPeerIDPrefix(strings.Repeat("x", MaxPeerIDPrefixLength+1))
+	assert.Equal(t, ErrPeerIDPrefixTooLong, err)
+}
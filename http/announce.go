@@ -2,6 +2,8 @@ package http
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -10,6 +12,8 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/zeebo/bencode"
 
@@ -24,6 +28,7 @@ type BaseAnnounceResponse struct {
 	MinInterval    int    `bencode:"min interval"`
 	Complete       int    `bencode:"complete"`
 	Incomplete     int    `bencode:"incomplete"`
+	TrackerID      string `bencode:"tracker id"`
 }
 
 // CompactAnnounceResponse is a template to parse a compact bencoded announce
@@ -54,21 +59,97 @@ type Client struct {
 	client          *http.Client
 	overrideCompact bool
 	compact         bool
+	roundTripper    poke.RoundTripper
+	tlsConfig       *tls.Config
+
+	mu        sync.Mutex
+	trackerID string
 }
 
-var _ poke.Announcer = &Client{}
+var (
+	_ poke.Announcer        = &Client{}
+	_ poke.ContextAnnouncer = &Client{}
+)
+
+// DefaultTimeout is the default timeout for a single request.
+const DefaultTimeout = 15 * time.Second
 
 // NewClient returns a new client for the given announce URI.
+//
+// Requests time out after DefaultTimeout.
 func NewClient(announceAddress string) (*Client, error) {
 	u, err := url.Parse(announceAddress)
 	if err != nil {
 		return nil, poke.WrapError("invalid announce URL", err)
 	}
 
-	return &Client{
+	c := &Client{
 		address: u,
-		client:  &http.Client{},
-	}, nil
+		client: &http.Client{
+			Timeout: DefaultTimeout,
+		},
+	}
+	c.roundTripper = poke.RoundTripperFunc(c.get)
+
+	return c, nil
+}
+
+// SetRoundTripper sets the RoundTripper used to perform requests.
+// The request passed to it is the URL to GET.
+func (c *Client) SetRoundTripper(rt poke.RoundTripper) {
+	c.roundTripper = rt
+}
+
+// RoundTripper returns the RoundTripper used to perform requests.
+// By default, requests are performed via HTTP.
+func (c *Client) RoundTripper() poke.RoundTripper {
+	return c.roundTripper
+}
+
+// SetTimeout sets the timeout for a single request, including reading the
+// response body.
+// A timeout of zero means no timeout.
+func (c *Client) SetTimeout(to time.Duration) {
+	c.client.Timeout = to
+}
+
+// get performs a GET request for the given URL and returns the response body.
+func (c *Client) get(ctx context.Context, u []byte) ([]byte, error) {
+	req, err := http.NewRequest("GET", string(u), nil)
+	if err != nil {
+		return nil, poke.WrapError("unable to create request", err)
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, poke.WrapError("unable to connect", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, poke.WrapError("unable to read", err)
+	}
+
+	return b, nil
+}
+
+// TrackerID returns the tracker id of the last announce response that carried
+// one.
+// It is sent with all announces that do not specify a tracker id.
+func (c *Client) TrackerID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.trackerID
+}
+
+// updateTrackerID remembers the tracker id of a response, if it has one.
+func (c *Client) updateTrackerID(r BaseAnnounceResponse) {
+	if r.TrackerID == "" {
+		return
+	}
+	c.mu.Lock()
+	c.trackerID = r.TrackerID
+	c.mu.Unlock()
 }
 
 // OverrideCompact instructs the Client to override the compact value set in an
@@ -80,17 +161,26 @@ func (c *Client) OverrideCompact(to bool) {
 
 // Announce announces to the tracker.
 func (c *Client) Announce(a poke.AnnounceRequest) (poke.OptionalAnnounceResponse, error) {
+	return c.AnnounceContext(context.Background(), a)
+}
+
+// useCompact reports whether the announce is performed as a compact announce.
+func (c *Client) useCompact(a poke.AnnounceRequest) bool {
+	if c.overrideCompact {
+		return c.compact
+	}
+	return a.Compact
+}
+
+// AnnounceRequestURL returns the URL the Client requests to perform the
+// announce.
+func (c *Client) AnnounceRequestURL(a poke.AnnounceRequest) (string, error) {
 	u, err := url.Parse(c.address.String())
 	if err != nil {
 		panic("url re-parse error")
 	}
 
-	var compact bool
-	if c.overrideCompact {
-		compact = c.compact
-	} else {
-		compact = a.Compact
-	}
+	compact := c.useCompact(a)
 
 	v := u.Query()
 	v.Set("info_hash", string(a.InfoHash))
@@ -115,12 +205,14 @@ func (c *Client) Announce(a poke.AnnounceRequest) (poke.OptionalAnnounceResponse
 	case poke.EventNone:
 
 	default:
-		return nil, errors.New("unknown event")
+		return "", errors.New("unknown event")
 	}
 
-	for _, b := range []byte(a.IP) {
-		if b != 0 {
-			v.Set("ip", a.IP.String())
+	// IPv6 addresses are sent via both ip and ipv6, see BEP 7.
+	if a.IP != nil && !a.IP.IsUnspecified() {
+		v.Set("ip", a.IP.String())
+		if a.IP.To4() == nil {
+			v.Set("ipv6", a.IP.String())
 		}
 	}
 
@@ -128,16 +220,52 @@ func (c *Client) Announce(a poke.AnnounceRequest) (poke.OptionalAnnounceResponse
 		v.Set("numwant", fmt.Sprint(a.Numwant))
 	}
 
+	if a.Key != 0 {
+		v.Set("key", fmt.Sprintf("%08X", a.Key))
+	}
+	trackerID := a.TrackerID
+	if trackerID == "" {
+		trackerID = c.TrackerID()
+	}
+	if trackerID != "" {
+		v.Set("trackerid", trackerID)
+	}
+	if a.NoPeerID {
+		v.Set("no_peer_id", "1")
+	}
+	if a.SupportCrypto {
+		v.Set("supportcrypto", "1")
+	}
+	if a.RequireCrypto {
+		v.Set("requirecrypto", "1")
+	}
+	if a.Corrupt != 0 {
+		v.Set("corrupt", fmt.Sprint(a.Corrupt))
+	}
+	if a.Redundant != 0 {
+		v.Set("redundant", fmt.Sprint(a.Redundant))
+	}
+	if a.LocalIP != nil {
+		v.Set("localip", a.LocalIP.String())
+	}
+
 	u.RawQuery = v.Encode()
-	poke.Debugf("Announcing: %s\n", u.String())
-	resp, err := c.client.Get(u.String())
+	return u.String(), nil
+}
+
+// AnnounceContext announces to the tracker, like Announce.
+// The request is aborted if ctx is done.
+func (c *Client) AnnounceContext(ctx context.Context, a poke.AnnounceRequest) (poke.OptionalAnnounceResponse, error) {
+	u, err := c.AnnounceRequestURL(a)
 	if err != nil {
-		return nil, poke.WrapError("unable to connect", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-	b, err := ioutil.ReadAll(resp.Body)
+	compact := c.useCompact(a)
+
+	poke.Debugf("Announcing: %s\n", u)
+	b, err := c.roundTripper.RoundTrip(ctx, []byte(u))
 	if err != nil {
-		return nil, poke.WrapError("unable to read", err)
+		return nil, err
 	}
 	poke.Debugf("Response: %s\n", string(b))
 
@@ -161,15 +289,24 @@ func (c *Client) Announce(a poke.AnnounceRequest) (poke.OptionalAnnounceResponse
 		if r.WarningMessage != "" {
 			return poke.WarningResponse(r.WarningMessage), nil
 		}
+		c.updateTrackerID(r.BaseAnnounceResponse)
 
 		ann := poke.AnnounceResponse{
 			Interval:    r.Interval,
 			MinInterval: r.MinInterval,
 			Incomplete:  r.Incomplete,
 			Complete:    r.Complete,
+			TrackerID:   r.TrackerID,
 			Peers:       make([]poke.Peer, 0),
 		}
 
+		if len(r.Peers)%6 != 0 {
+			return nil, fmt.Errorf("invalid length of peers: %d", len(r.Peers))
+		}
+		if len(r.Peers6)%18 != 0 {
+			return nil, fmt.Errorf("invalid length of peers6: %d", len(r.Peers6))
+		}
+
 		for i := 0; i < len(r.Peers); i += 6 {
 			peer := poke.Peer{}
 			peer.IP = net.IPv4(r.Peers[0+i], r.Peers[1+i], r.Peers[2+i], r.Peers[3+i])
@@ -184,7 +321,7 @@ func (c *Client) Announce(a poke.AnnounceRequest) (poke.OptionalAnnounceResponse
 		for i := 0; i < len(r.Peers6); i += 18 {
 			peer := poke.Peer{}
 			peer.IP = net.IP(r.Peers6[i+0 : i+16])
-			reader := bytes.NewBuffer(r.Peers[i+16 : i+18])
+			reader := bytes.NewBuffer(r.Peers6[i+16 : i+18])
 			err = binary.Read(reader, binary.BigEndian, &peer.Port)
 			if err != nil {
 				return nil, poke.WrapError("unable to decode port", err)
@@ -214,12 +351,14 @@ func (c *Client) Announce(a poke.AnnounceRequest) (poke.OptionalAnnounceResponse
 	if r.WarningMessage != "" {
 		return poke.WarningResponse(r.WarningMessage), nil
 	}
+	c.updateTrackerID(r.BaseAnnounceResponse)
 
 	ann := poke.AnnounceResponse{
 		Interval:    r.Interval,
 		MinInterval: r.MinInterval,
 		Incomplete:  r.Incomplete,
 		Complete:    r.Complete,
+		TrackerID:   r.TrackerID,
 		Peers:       make([]poke.Peer, 0),
 	}
 
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+func TestAnnounceIPv6(t *testing.T) {
+	ip := net.ParseIP("2001:db8::1")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ip.String(), r.URL.Query().Get("ip"))
+		assert.Equal(t, ip.String(), r.URL.Query().Get("ipv6"))
+		w.Write([]byte("d8:intervali60e5:peers6:\x01\x02\x03\x04\x00\x506:peers618:" +
+			"\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x1a\xe1e"))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/announce")
+	require.Nil(t, err)
+
+	resp, err := c.Announce(poke.AnnounceRequest{
+		InfoHash: poke.InfoHash("aaaaaaaaaaaaaaaaaaaa"),
+		Compact:  true,
+		Event:    poke.EventStarted,
+		Peer: poke.Peer{
+			ID:   "-POKE64-000000012345",
+			Port: 12345,
+			IP:   ip,
+		},
+	})
+	require.Nil(t, err)
+	require.IsType(t, poke.AnnounceResponse{}, resp)
+
+	peers := resp.(poke.AnnounceResponse).Peers
+	require.Equal(t, 2, len(peers))
+	assert.True(t, net.IPv4(1, 2, 3, 4).Equal(peers[0].IP))
+	assert.Equal(t, uint16(80), peers[0].Port)
+	assert.True(t, net.ParseIP("2001:db8::2").Equal(peers[1].IP))
+	assert.Equal(t, uint16(6881), peers[1].Port)
+}
+
+func TestAnnounceTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	c, err := NewClient(srv.URL + "/announce")
+	require.Nil(t, err)
+	c.SetTimeout(10 * time.Millisecond)
+
+	_, err = c.Announce(poke.AnnounceRequest{Event: poke.EventStarted})
+	assert.NotNil(t, err)
+
+	c.SetTimeout(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c.AnnounceContext(ctx, poke.AnnounceRequest{Event: poke.EventStarted})
+	assert.NotNil(t, err)
+}
+
+func TestAnnounceParameters(t *testing.T) {
+	var trackerIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		trackerIDs = append(trackerIDs, q.Get("trackerid"))
+		assert.Equal(t, "0000ABCD", q.Get("key"))
+		assert.Equal(t, "1", q.Get("no_peer_id"))
+		assert.Equal(t, "1", q.Get("supportcrypto"))
+		assert.Equal(t, "", q.Get("requirecrypto"))
+		assert.Equal(t, "10", q.Get("corrupt"))
+		assert.Equal(t, "20", q.Get("redundant"))
+		assert.Equal(t, "192.168.1.2", q.Get("localip"))
+		w.Write([]byte("d8:intervali60e5:peers0:10:tracker id4:pokee"))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/announce")
+	require.Nil(t, err)
+
+	req := poke.AnnounceRequest{
+		InfoHash:      poke.InfoHash("aaaaaaaaaaaaaaaaaaaa"),
+		Compact:       true,
+		Event:         poke.EventStarted,
+		Key:           0xABCD,
+		NoPeerID:      true,
+		SupportCrypto: true,
+		Corrupt:       10,
+		Redundant:     20,
+		LocalIP:       net.IPv4(192, 168, 1, 2),
+		Peer: poke.Peer{
+			ID:   "-POKE64-000000012345",
+			Port: 12345,
+		},
+	}
+
+	resp, err := c.Announce(req)
+	require.Nil(t, err)
+	require.IsType(t, poke.AnnounceResponse{}, resp)
+	assert.Equal(t, "poke", resp.(poke.AnnounceResponse).TrackerID)
+	assert.Equal(t, "poke", c.TrackerID())
+
+	_, err = c.Announce(req)
+	require.Nil(t, err)
+
+	req.TrackerID = "other"
+	_, err = c.Announce(req)
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{"", "poke", "other"}, trackerIDs)
+}
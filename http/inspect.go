@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// Response is an HTTP response as sent by a tracker.
+//
+// Body is the body as transferred: chunked bodies are joined, but the content
+// encoding is not decoded.
+type Response struct {
+	StatusCode       int
+	Header           http.Header
+	TransferEncoding []string
+	Body             []byte
+}
+
+// Inspect performs a GET request for the URL, which is usually obtained from
+// AnnounceRequestURL or ScrapeRequestURL, and returns the response of the
+// tracker as is.
+// Redirects are not followed. If acceptEncoding is not empty, it is sent as
+// the Accept-Encoding header.
+//
+// Inspect does not use the RoundTripper of the Client, but its TLS
+// configuration.
+func (c *Client) Inspect(ctx context.Context, u string, acceptEncoding string) (Response, error) {
+	tr := newTransport(c.tlsConfig, true)
+	defer tr.CloseIdleConnections()
+
+	client := &http.Client{
+		Timeout:   c.client.Timeout,
+		Transport: tr,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return Response{}, poke.WrapError("unable to create request", err)
+	}
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return Response{}, poke.WrapError("unable to connect", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, poke.WrapError("unable to read", err)
+	}
+
+	return Response{
+		StatusCode:       resp.StatusCode,
+		Header:           resp.Header,
+		TransferEncoding: resp.TransferEncoding,
+		Body:             b,
+	}, nil
+}
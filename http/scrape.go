@@ -0,0 +1,128 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/zeebo/bencode"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// ErrScrapeNotSupported is returned if the scrape URL cannot be derived from
+// the announce URL, as specified in BEP 48.
+var ErrScrapeNotSupported = errors.New("tracker does not support scrape")
+
+// ScrapeFile is a template to parse the bencoded scrape information for a
+// single infohash into.
+type ScrapeFile struct {
+	Complete   int `bencode:"complete"`
+	Downloaded int `bencode:"downloaded"`
+	Incomplete int `bencode:"incomplete"`
+}
+
+// ScrapeResponse is a template to parse a bencoded scrape response into.
+type ScrapeResponse struct {
+	FailureReason string                `bencode:"failure reason"`
+	Files         map[string]ScrapeFile `bencode:"files"`
+}
+
+var (
+	_ poke.Scraper        = &Client{}
+	_ poke.ContextScraper = &Client{}
+)
+
+// ScrapeURL derives the scrape URL from an announce URL as specified in
+// BEP 48: The last path segment must start with "announce", which is replaced
+// by "scrape".
+//
+// ErrScrapeNotSupported is returned if the announce URL does not follow this
+// convention.
+func ScrapeURL(announce *url.URL) (*url.URL, error) {
+	i := strings.LastIndex(announce.Path, "/")
+	if i < 0 || !strings.HasPrefix(announce.Path[i+1:], "announce") {
+		return nil, ErrScrapeNotSupported
+	}
+
+	u, err := url.Parse(announce.String())
+	if err != nil {
+		panic("url re-parse error")
+	}
+	u.Path = announce.Path[:i+1] + "scrape" + strings.TrimPrefix(announce.Path[i+1:], "announce")
+	u.RawPath = ""
+
+	return u, nil
+}
+
+// Scrape scrapes the tracker.
+//
+// The files of the response are returned in the order of the infohashes in
+// the request. Infohashes not present in the tracker's response are omitted.
+//
+// This implements poke.Scraper for HTTP clients.
+func (c *Client) Scrape(s poke.ScrapeRequest) (poke.OptionalScrapeResponse, error) {
+	return c.ScrapeContext(context.Background(), s)
+}
+
+// ScrapeRequestURL returns the URL the Client requests to perform the scrape.
+func (c *Client) ScrapeRequestURL(s poke.ScrapeRequest) (string, error) {
+	u, err := ScrapeURL(c.address)
+	if err != nil {
+		return "", err
+	}
+
+	v := u.Query()
+	for _, ih := range s.InfoHashes {
+		v.Add("info_hash", string(ih))
+	}
+
+	u.RawQuery = v.Encode()
+	return u.String(), nil
+}
+
+// ScrapeContext scrapes the tracker, like Scrape.
+// The request is aborted if ctx is done.
+func (c *Client) ScrapeContext(ctx context.Context, s poke.ScrapeRequest) (poke.OptionalScrapeResponse, error) {
+	u, err := c.ScrapeRequestURL(s)
+	if err != nil {
+		return nil, err
+	}
+
+	poke.Debugf("Scraping: %s\n", u)
+	b, err := c.roundTripper.RoundTrip(ctx, []byte(u))
+	if err != nil {
+		return nil, err
+	}
+	poke.Debugf("Response: %s\n", string(b))
+
+	r := ScrapeResponse{}
+	err = bencode.DecodeBytes(b, &r)
+	if err != nil {
+		return nil, poke.WrapError("unable to decode", err)
+	}
+
+	if r.FailureReason != "" {
+		return poke.ErrorResponse(r.FailureReason), nil
+	}
+
+	scrape := poke.ScrapeResponse{
+		Files: make([]poke.Scrape, 0, len(s.InfoHashes)),
+	}
+
+	for _, ih := range s.InfoHashes {
+		f, ok := r.Files[string(ih)]
+		if !ok {
+			continue
+		}
+		scrape.Files = append(scrape.Files, poke.Scrape{
+			InfoHash:   ih,
+			Complete:   f.Complete,
+			Downloaded: f.Downloaded,
+			Incomplete: f.Incomplete,
+		})
+	}
+
+	return scrape, nil
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+func TestScrapeURL(t *testing.T) {
+	table := []struct {
+		announce string
+		scrape   string
+	}{
+		{"http://example.com/announce", "http://example.com/scrape"},
+		{"http://example.com/x/announce", "http://example.com/x/scrape"},
+		{"http://example.com/announce.php", "http://example.com/scrape.php"},
+		{"http://example.com/announce?x2%0644", "http://example.com/scrape?x2%0644"},
+		{"http://example.com/announce?x=2/4", "http://example.com/scrape?x=2/4"},
+		{"http://example.com/x%064announce", ""},
+		{"http://example.com/a", ""},
+		{"http://example.com/announce/x", ""},
+	}
+
+	for _, tt := range table {
+		a, err := url.Parse(tt.announce)
+		require.Nil(t, err)
+
+		s, err := ScrapeURL(a)
+		if tt.scrape == "" {
+			assert.Equal(t, ErrScrapeNotSupported, err, tt.announce)
+			continue
+		}
+		require.Nil(t, err, tt.announce)
+		assert.Equal(t, tt.scrape, s.String())
+	}
+}
+
+func TestScrape(t *testing.T) {
+	ih1 := poke.InfoHash("aaaaaaaaaaaaaaaaaaaa")
+	ih2 := poke.InfoHash("bbbbbbbbbbbbbbbbbbbb")
+	ih3 := poke.InfoHash("cccccccccccccccccccc")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/scrape", r.URL.Path)
+		assert.Equal(t, []string{string(ih2), string(ih1), string(ih3)}, r.URL.Query()["info_hash"])
+		w.Write([]byte("d5:filesd" +
+			"20:aaaaaaaaaaaaaaaaaaaad8:completei1e10:downloadedi2e10:incompletei3ee" +
+			"20:bbbbbbbbbbbbbbbbbbbbd8:completei4e10:downloadedi5e10:incompletei6ee" +
+			"ee"))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/announce")
+	require.Nil(t, err)
+
+	resp, err := c.Scrape(poke.ScrapeRequest{InfoHashes: []poke.InfoHash{ih2, ih1, ih3}})
+	require.Nil(t, err)
+	require.IsType(t, poke.ScrapeResponse{}, resp)
+
+	files := resp.(poke.ScrapeResponse).Files
+	require.Equal(t, 2, len(files))
+	assert.Equal(t, poke.Scrape{InfoHash: ih2, Complete: 4, Downloaded: 5, Incomplete: 6}, files[0])
+	assert.Equal(t, poke.Scrape{InfoHash: ih1, Complete: 1, Downloaded: 2, Incomplete: 3}, files[1])
+}
+
+func TestScrapeFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("d14:failure reason9:forbiddene"))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/announce")
+	require.Nil(t, err)
+
+	resp, err := c.Scrape(poke.ScrapeRequest{InfoHashes: []poke.InfoHash{poke.InfoHash("aaaaaaaaaaaaaaaaaaaa")}})
+	require.Nil(t, err)
+	assert.Equal(t, poke.ErrorResponse("forbidden"), resp)
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// ErrNoCertificates is returned if a CA file does not contain any PEM encoded
+// certificates.
+var ErrNoCertificates = errors.New("no certificates found")
+
+// TLSOptions configure the connections of a Client to HTTPS trackers.
+type TLSOptions struct {
+	// CAFile is a PEM file with the CA certificates used to verify the
+	// tracker's certificate instead of the system's.
+	CAFile string
+
+	// CertFile and KeyFile are PEM files with a client certificate and its
+	// key, which are presented to trackers that require mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// ServerName overrides the host name sent via SNI and expected in the
+	// tracker's certificate.
+	ServerName string
+
+	// InsecureSkipVerify disables verifying the tracker's certificate.
+	InsecureSkipVerify bool
+}
+
+// TLSConfig loads the files of the options and returns the resulting TLS
+// configuration.
+func (o TLSOptions) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		b, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, poke.WrapError("unable to read CA file", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(b) {
+			return nil, poke.WrapError("invalid CA file "+o.CAFile, ErrNoCertificates)
+		}
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, poke.WrapError("unable to load client certificate", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// newTransport creates an http.Transport using the TLS configuration, which
+// may be nil.
+func newTransport(cfg *tls.Config, disableCompression bool) *http.Transport {
+	return &http.Transport{
+		Proxy:              http.ProxyFromEnvironment,
+		TLSClientConfig:    cfg,
+		DisableCompression: disableCompression,
+	}
+}
+
+// SetTLSConfig sets the TLS configuration used to connect to HTTPS trackers.
+func (c *Client) SetTLSConfig(cfg *tls.Config) {
+	c.tlsConfig = cfg
+	c.client.Transport = newTransport(cfg, false)
+}
+
+// TLSConfig returns the TLS configuration used to connect to HTTPS trackers,
+// or nil if the default configuration is used.
+func (c *Client) TLSConfig() *tls.Config {
+	return c.tlsConfig
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// writeClientCertificate creates a self-signed client certificate and writes
+// it and its key to PEM files in dir.
+func writeClientCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "poke"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	require.Nil(t, err)
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	require.Nil(t, err)
+
+	return cert, certFile, keyFile
+}
+
+func TestTLSOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poke")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	clientCert, certFile, keyFile := writeClientCertificate(t, dir)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("d8:intervali60e5:peers0:e"))
+	}))
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  x509.NewCertPool(),
+	}
+	srv.TLS.ClientCAs.AddCert(clientCert)
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+	require.Nil(t, err)
+
+	req := poke.AnnounceRequest{
+		InfoHash: poke.InfoHash("aaaaaaaaaaaaaaaaaaaa"),
+		Compact:  true,
+		Event:    poke.EventStarted,
+		Peer: poke.Peer{
+			ID:   "-POKE64-000000012345",
+			Port: 12345,
+		},
+	}
+
+	table := []struct {
+		opts TLSOptions
+		ok   bool
+	}{
+		{TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, true},
+		{TLSOptions{CAFile: caFile}, false},
+		{TLSOptions{CertFile: certFile, KeyFile: keyFile}, false},
+		{TLSOptions{CertFile: certFile, KeyFile: keyFile, InsecureSkipVerify: true}, true},
+		// The test certificate is valid for example.com.
+		{TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "example.com"}, true},
+		{TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "example.org"}, false},
+	}
+
+	for i, tt := range table {
+		cfg, err := tt.opts.TLSConfig()
+		require.Nil(t, err)
+
+		c, err := NewClient(srv.URL + "/announce")
+		require.Nil(t, err)
+		c.SetTLSConfig(cfg)
+		assert.Equal(t, cfg, c.TLSConfig())
+
+		_, err = c.Announce(req)
+		assert.Equal(t, tt.ok, err == nil, "%d: %v", i, err)
+	}
+
+	_, err = TLSOptions{CAFile: keyFile}.TLSConfig()
+	assert.NotNil(t, err)
+	_, err = TLSOptions{CertFile: certFile}.TLSConfig()
+	assert.NotNil(t, err)
+}
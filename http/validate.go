@@ -0,0 +1,275 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A Finding is a deviation of a response from canonical bencode or from the
+// structure of tracker responses.
+type Finding struct {
+	// Offset is the offset in the response the deviation was found at.
+	Offset  int    `json:"offset"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("offset %d: %s", f.Offset, f.Message)
+}
+
+// maxDepth is the maximum nesting depth of lists and dictionaries a response
+// is validated to.
+const maxDepth = 32
+
+// bvalue is a decoded bencode value, along with its offset in the response.
+type bvalue struct {
+	kind   byte // 'i', 's', 'l' or 'd'
+	offset int
+	str    []byte
+	list   []bvalue
+	keys   []string
+	dict   map[string]bvalue
+}
+
+func (v bvalue) kindName() string {
+	switch v.kind {
+	case 'i':
+		return "an integer"
+	case 's':
+		return "a string"
+	case 'l':
+		return "a list"
+	}
+	return "a dictionary"
+}
+
+type validator struct {
+	b        []byte
+	pos      int
+	findings []Finding
+}
+
+func (v *validator) report(offset int, format string, args ...interface{}) {
+	v.findings = append(v.findings, Finding{
+		Offset:  offset,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// malformed reports that the response is not bencode at all.
+// Validation stops at the first malformed value.
+func (v *validator) malformed(offset int, format string, args ...interface{}) (bvalue, bool) {
+	v.report(offset, "malformed bencode: "+format, args...)
+	return bvalue{}, false
+}
+
+// digits reads decimal digits up to the terminator and returns them.
+func (v *validator) digits(terminator byte) ([]byte, bool) {
+	start := v.pos
+	for v.pos < len(v.b) && v.b[v.pos] != terminator {
+		if v.b[v.pos] < '0' || v.b[v.pos] > '9' {
+			return nil, false
+		}
+		v.pos++
+	}
+	if v.pos == len(v.b) {
+		return nil, false
+	}
+	d := v.b[start:v.pos]
+	v.pos++ // terminator
+	return d, true
+}
+
+func (v *validator) value(depth int) (bvalue, bool) {
+	if v.pos >= len(v.b) {
+		return v.malformed(v.pos, "unexpected end of response")
+	}
+	if depth > maxDepth {
+		return v.malformed(v.pos, "nested deeper than %d levels", maxDepth)
+	}
+
+	start := v.pos
+	switch c := v.b[v.pos]; {
+	case c == 'i':
+		v.pos++
+		negative := v.pos < len(v.b) && v.b[v.pos] == '-'
+		if negative {
+			v.pos++
+		}
+		d, ok := v.digits('e')
+		if !ok || len(d) == 0 {
+			return v.malformed(start, "invalid integer")
+		}
+		switch {
+		case negative && len(d) == 1 && d[0] == '0':
+			v.report(start, "negative zero integer")
+		case len(d) > 1 && d[0] == '0':
+			v.report(start, "integer with leading zero")
+		}
+		return bvalue{kind: 'i', offset: start, str: v.b[start+1 : v.pos-1]}, true
+
+	case c >= '0' && c <= '9':
+		d, ok := v.digits(':')
+		if !ok {
+			return v.malformed(start, "invalid string length")
+		}
+		if len(d) > 1 && d[0] == '0' {
+			v.report(start, "string length with leading zero")
+		}
+		var n int
+		for _, digit := range d {
+			n = n*10 + int(digit-'0')
+			if n > len(v.b) {
+				break
+			}
+		}
+		if n > len(v.b)-v.pos {
+			return v.malformed(start, "string longer than the response")
+		}
+		s := v.b[v.pos : v.pos+n]
+		v.pos += n
+		return bvalue{kind: 's', offset: start, str: s}, true
+
+	case c == 'l':
+		v.pos++
+		l := bvalue{kind: 'l', offset: start}
+		for v.pos < len(v.b) && v.b[v.pos] != 'e' {
+			e, ok := v.value(depth + 1)
+			if !ok {
+				return e, false
+			}
+			l.list = append(l.list, e)
+		}
+		if v.pos == len(v.b) {
+			return v.malformed(start, "unterminated list")
+		}
+		v.pos++
+		return l, true
+
+	case c == 'd':
+		v.pos++
+		d := bvalue{kind: 'd', offset: start, dict: make(map[string]bvalue)}
+		var last []byte
+		for v.pos < len(v.b) && v.b[v.pos] != 'e' {
+			if c := v.b[v.pos]; c < '0' || c > '9' {
+				return v.malformed(v.pos, "dictionary key is not a string")
+			}
+			k, ok := v.value(depth + 1)
+			if !ok {
+				return k, false
+			}
+			switch {
+			case last == nil:
+			case bytes.Equal(k.str, last):
+				v.report(k.offset, "duplicate dictionary key %q", k.str)
+			case bytes.Compare(k.str, last) < 0:
+				v.report(k.offset, "dictionary key %q is not sorted after %q", k.str, last)
+			}
+			last = k.str
+
+			e, ok := v.value(depth + 1)
+			if !ok {
+				return e, false
+			}
+			if _, ok := d.dict[string(k.str)]; !ok {
+				d.keys = append(d.keys, string(k.str))
+			}
+			d.dict[string(k.str)] = e
+		}
+		if v.pos == len(v.b) {
+			return v.malformed(start, "unterminated dictionary")
+		}
+		v.pos++
+		return d, true
+	}
+
+	return v.malformed(start, "unexpected byte %q", v.b[v.pos])
+}
+
+// expect reports the value of a dictionary if it is not of the given kind.
+func (v *validator) expect(d bvalue, key string, kind byte) (bvalue, bool) {
+	e, ok := d.dict[key]
+	if !ok {
+		return e, false
+	}
+	if e.kind != kind {
+		v.report(e.offset, "%q is %s, not %s", key, e.kindName(), bvalue{kind: kind}.kindName())
+		return e, false
+	}
+	return e, true
+}
+
+// response checks the structure of an announce or scrape response.
+func (v *validator) response(r bvalue) {
+	if r.kind != 'd' {
+		v.report(r.offset, "response is %s, not a dictionary", r.kindName())
+		return
+	}
+
+	for _, k := range []string{"interval", "min interval", "complete", "incomplete"} {
+		v.expect(r, k, 'i')
+	}
+	for _, k := range []string{"failure reason", "warning message", "tracker id"} {
+		v.expect(r, k, 's')
+	}
+
+	if p, ok := r.dict["peers"]; ok {
+		switch p.kind {
+		case 's':
+			if len(p.str)%6 != 0 {
+				v.report(p.offset, "length of peers is %d, not a multiple of 6", len(p.str))
+			}
+		case 'l':
+			for _, peer := range p.list {
+				if peer.kind != 'd' {
+					v.report(peer.offset, "peer is %s, not a dictionary", peer.kindName())
+					continue
+				}
+				v.expect(peer, "ip", 's')
+				v.expect(peer, "peer id", 's')
+				v.expect(peer, "port", 'i')
+			}
+		default:
+			v.report(p.offset, "\"peers\" is %s, not a string or a list", p.kindName())
+		}
+	}
+
+	if p, ok := v.expect(r, "peers6", 's'); ok && len(p.str)%18 != 0 {
+		v.report(p.offset, "length of peers6 is %d, not a multiple of 18", len(p.str))
+	}
+
+	if files, ok := v.expect(r, "files", 'd'); ok {
+		for _, k := range files.keys {
+			f := files.dict[k]
+			if f.kind != 'd' {
+				v.report(f.offset, "scrape of %x is %s, not a dictionary", k, f.kindName())
+				continue
+			}
+			for _, k := range []string{"complete", "downloaded", "incomplete"} {
+				v.expect(f, k, 'i')
+			}
+		}
+	}
+}
+
+// Validate checks that the response of a tracker is canonical bencode and
+// that the values of the keys of announce and scrape responses have the
+// expected types.
+//
+// It returns all deviations found, or nil if there are none. Validation stops
+// at the first value that is not bencode at all.
+func Validate(b []byte) []Finding {
+	v := &validator{b: b}
+
+	r, ok := v.value(0)
+	if !ok {
+		return v.findings
+	}
+	if v.pos < len(b) {
+		v.report(v.pos, "%d bytes of trailing data", len(b)-v.pos)
+	}
+
+	v.response(r)
+
+	return v.findings
+}
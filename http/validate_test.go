@@ -0,0 +1,43 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	table := []struct {
+		response string
+		findings []Finding
+	}{
+		{"d8:intervali60e5:peers6:\x01\x02\x03\x04\x00\x50e", nil},
+		{"d8:completei0e10:incompletei1e8:intervali60e5:peersld2:ip7:1.2.3.47:peer id20:aaaaaaaaaaaaaaaaaaaa4:porti80eeee", nil},
+		{"d5:filesd20:aaaaaaaaaaaaaaaaaaaad8:completei1e10:downloadedi0e10:incompletei2eeee", nil},
+		{"d14:failure reason5:errore", nil},
+		{"d8:intervali060e5:peers0:e", []Finding{{11, "integer with leading zero"}}},
+		{"d8:intervali-0e5:peers0:e", []Finding{{11, "negative zero integer"}}},
+		{"d8:interval02:605:peers0:e", []Finding{
+			{11, "string length with leading zero"},
+			{11, `"interval" is a string, not an integer`},
+		}},
+		{"d5:peers0:8:intervali60ee", []Finding{{10, `dictionary key "interval" is not sorted after "peers"`}}},
+		{"d8:intervali60e8:intervali60ee", []Finding{{15, `duplicate dictionary key "interval"`}}},
+		{"d8:intervali60e5:peers0:ex", []Finding{{25, "1 bytes of trailing data"}}},
+		{"d8:intervali60e5:peers5:abcdee", []Finding{{22, "length of peers is 5, not a multiple of 6"}}},
+		{"d8:intervali60e5:peers0:6:peers66:abcdefe", []Finding{{32, "length of peers6 is 6, not a multiple of 18"}}},
+		{"d8:intervali60e5:peersi1ee", []Finding{{22, `"peers" is an integer, not a string or a list`}}},
+		{"d8:intervali60e5:peerslli1eeee", []Finding{{23, "peer is a list, not a dictionary"}}},
+		{"li1ee", []Finding{{0, "response is a list, not a dictionary"}}},
+		{"", []Finding{{0, "malformed bencode: unexpected end of response"}}},
+		{"d8:intervali60e", []Finding{{0, "malformed bencode: unterminated dictionary"}}},
+		{"d8:intervalie", []Finding{{11, "malformed bencode: invalid integer"}}},
+		{"di1ei2ee", []Finding{{1, "malformed bencode: dictionary key is not a string"}}},
+		{"d8:interval99:ae", []Finding{{11, "malformed bencode: string longer than the response"}}},
+		{"<html>", []Finding{{0, "malformed bencode: unexpected byte '<'"}}},
+	}
+
+	for _, tt := range table {
+		assert.Equal(t, tt.findings, Validate([]byte(tt.response)), tt.response)
+	}
+}
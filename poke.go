@@ -1,17 +1,14 @@
 package poke
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"math/rand"
 	"net"
-	"sync"
 )
 
-func init() {
-	infoHashes = make(map[storableInfoHash]struct{})
-	peerData = make(map[uint16]struct{})
-}
+// Version is the version of poke.
+const Version = "0.1.0"
 
 // Debug enables debug logging.
 var Debug bool
@@ -19,7 +16,7 @@ var Debug bool
 // Debugf writes to the log a formatted message if Debug==true.
 func Debugf(format string, v ...interface{}) {
 	if Debug {
-		log.Printf(format, v)
+		log.Printf(format, v...)
 	}
 }
 
@@ -35,67 +32,9 @@ const (
 	EventInvalid = 9
 )
 
-var (
-	infoHashes    map[storableInfoHash]struct{}
-	infoHashesMut sync.Mutex
-
-	peerData    map[uint16]struct{}
-	peerDataMut sync.Mutex
-)
-
 // InfoHash represents a 20-byte infohash in hexadecimal format.
 type InfoHash []byte
 
-type storableInfoHash [20]byte
-
-// NewInfohash generates a unique, random InfoHash.
-func NewInfohash(r *rand.Rand) InfoHash {
-	infoHash := [20]byte{}
-	b := make([]byte, 20)
-	i, err := r.Read(b)
-	if err != nil || i != 20 {
-		panic("could not read 20 random bytes")
-	}
-
-	copy(infoHash[:], b)
-
-	infoHashesMut.Lock()
-	if _, ok := infoHashes[infoHash]; ok {
-		infoHashesMut.Unlock()
-		return NewInfohash(r)
-	}
-
-	infoHashes[infoHash] = struct{}{}
-	infoHashesMut.Unlock()
-
-	return infoHash[:]
-}
-
-const peerPrefix = "-POKE64-0000000"
-
-// NewPeer generates a random, unique Peer.
-// The peer will have its ID prefixed by peerPrefix.
-func NewPeer(r *rand.Rand) Peer {
-	baseVal := uint16((r.Int() % 65536) + 1024)
-
-	peer := Peer{
-		Port: baseVal,
-		IP:   net.IPv4(64, 64, byte(baseVal&0xFF), byte(baseVal>>8)),
-		ID:   peerPrefix + fmt.Sprintf("%05d", baseVal),
-	}
-
-	peerDataMut.Lock()
-	if _, ok := peerData[baseVal]; ok {
-		peerDataMut.Unlock()
-		return NewPeer(r)
-	}
-
-	peerData[baseVal] = struct{}{}
-	peerDataMut.Unlock()
-
-	return peer
-}
-
 // Peer represents a peer in a BitTorrent swarm.
 type Peer struct {
 	ID   string
@@ -106,8 +45,9 @@ type Peer struct {
 // IsEqual compares the peer to another peer and returns true if their ports
 // are equal.
 //
-// This works for Peers generated by Poke because they are guaranteed to have
-// unique ports.
+// This works for the first 64,512 Peers generated by the same Generator,
+// because they are guaranteed to have unique ports. Larger populations must be
+// compared using IsReallyEqual.
 func (p Peer) IsEqual(other Peer) bool {
 	return p.Port == other.Port
 }
@@ -119,6 +59,9 @@ func (p Peer) IsReallyEqual(other Peer) bool {
 }
 
 // AnnounceRequest represents an announce request.
+//
+// Key, TrackerID, Corrupt, Redundant and LocalIP are only sent if they are
+// set. Only Key is sent via UDP.
 type AnnounceRequest struct {
 	InfoHash   InfoHash
 	Uploaded   int
@@ -127,6 +70,31 @@ type AnnounceRequest struct {
 	Compact    bool
 	Event      Event
 	Numwant    int
+
+	// Key identifies the client if its IP address changes.
+	Key uint32
+
+	// TrackerID is the tracker id to send. If it is empty, HTTP clients send
+	// the tracker id of the last response that carried one.
+	TrackerID string
+
+	// NoPeerID asks the tracker to leave out peer IDs from non-compact
+	// responses.
+	NoPeerID bool
+
+	// SupportCrypto and RequireCrypto announce that the client supports or
+	// requires encrypted connections.
+	SupportCrypto bool
+	RequireCrypto bool
+
+	// Corrupt and Redundant are the number of bytes downloaded that failed
+	// the hash check or were downloaded twice.
+	Corrupt   int
+	Redundant int
+
+	// LocalIP is the address of the client in its local network.
+	LocalIP net.IP
+
 	Peer
 }
 
@@ -157,6 +125,7 @@ type AnnounceResponse struct {
 	MinInterval int
 	Complete    int
 	Incomplete  int
+	TrackerID   string
 	Peers       []Peer
 }
 
@@ -194,6 +163,34 @@ type Scraper interface {
 	Scrape(ScrapeRequest) (OptionalScrapeResponse, error)
 }
 
+// ContextAnnouncer provides the AnnounceContext method, which aborts the
+// announce if the context is done.
+type ContextAnnouncer interface {
+	AnnounceContext(context.Context, AnnounceRequest) (OptionalAnnounceResponse, error)
+}
+
+// ContextScraper provides the ScrapeContext method, which aborts the scrape if
+// the context is done.
+type ContextScraper interface {
+	ScrapeContext(context.Context, ScrapeRequest) (OptionalScrapeResponse, error)
+}
+
+// RoundTripper exchanges a raw request for a raw response with a tracker.
+//
+// For HTTP trackers, the request is the URL to GET and the response is the
+// response body. For UDP trackers, request and response are datagrams.
+type RoundTripper interface {
+	RoundTrip(ctx context.Context, request []byte) ([]byte, error)
+}
+
+// RoundTripperFunc is an adapter to use a function as a RoundTripper.
+type RoundTripperFunc func(ctx context.Context, request []byte) ([]byte, error)
+
+// RoundTrip implements RoundTripper.
+func (f RoundTripperFunc) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	return f(ctx, request)
+}
+
 // WrapError wraps an error inside another error, adding a higher-level
 // description of what happened.
 func WrapError(msg string, err error) error {
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the Reports to w as JUnit XML, with one testsuite per
+// Report.
+//
+// Every test becomes a testcase. Tests that were not run are reported as
+// skipped, tests that returned an error as failed. Findings of a test are
+// written to its system-err.
+func WriteJUnit(w io.Writer, rs ...Report) error {
+	suites := junitTestSuites{
+		Suites: make([]junitTestSuite, 0, len(rs)),
+	}
+	for _, r := range rs {
+		suites.Suites = append(suites.Suites, newJUnitTestSuite(r))
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(suites)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func newJUnitTestSuite(r Report) junitTestSuite {
+	res := r.TrackerResult()
+	suite := junitTestSuite{
+		Name:  "poke " + r.Tracker,
+		Tests: len(res.Tests),
+		Properties: []junitProperty{
+			{Name: "pokeVersion", Value: r.PokeVersion},
+			{Name: "tracker", Value: r.Tracker},
+			{Name: "protocol", Value: r.Protocol},
+			{Name: "seed", Value: strconv.FormatInt(res.Seed, 10)},
+		},
+	}
+
+	var total time.Duration
+	for _, t := range res.Tests {
+		tc := junitTestCase{
+			Name:      t.Name,
+			ClassName: "poke." + r.Protocol,
+			Time:      formatSeconds(t.Duration),
+		}
+		total += t.Duration
+
+		switch {
+		case !t.Run:
+			tc.Skipped = &junitSkipped{Message: t.NotRunReason}
+			suite.Skipped++
+		case t.Result.Err != nil:
+			tc.Failure = &junitFailure{
+				Message: t.Result.Err.Error(),
+				Body:    t.Result.Err.Error(),
+			}
+			suite.Failures++
+		}
+
+		if t.Run && t.Result.Result != nil {
+			tc.SystemOut = formatResult(t.Result.Result)
+		}
+		for _, f := range t.Result.Findings {
+			tc.SystemErr += "finding: " + f.String() + "\n"
+		}
+
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Time = formatSeconds(total)
+
+	return suite
+}
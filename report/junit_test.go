@@ -0,0 +1,73 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke/http"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := WriteJUnit(buf, testReport())
+	require.Nil(t, err)
+
+	var suites junitTestSuites
+	err = xml.Unmarshal(buf.Bytes(), &suites)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(suites.Suites))
+
+	suite := suites.Suites[0]
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, 1, suite.Skipped)
+	assert.Equal(t, "1.000", suite.Time)
+	assert.Contains(t, suite.Properties, junitProperty{Name: "seed", Value: "42"})
+	require.Equal(t, 3, len(suite.TestCases))
+
+	assert.Equal(t, "passing", suite.TestCases[0].Name)
+	assert.Equal(t, "poke.http", suite.TestCases[0].ClassName)
+	assert.Nil(t, suite.TestCases[0].Failure)
+	assert.Nil(t, suite.TestCases[0].Skipped)
+
+	require.NotNil(t, suite.TestCases[1].Failure)
+	assert.Equal(t, "some error", suite.TestCases[1].Failure.Message)
+
+	require.NotNil(t, suite.TestCases[2].Skipped)
+	assert.Equal(t, "some reason", suite.TestCases[2].Skipped.Message)
+}
+
+func TestWriteJUnitFindings(t *testing.T) {
+	r := testReport()
+	r.HTTP.Tests[1].Result.Findings = []http.Finding{
+		{Offset: 0, Message: "response is a list, not a dictionary"},
+		{Offset: 5, Message: "1 bytes of trailing data"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := WriteJUnit(buf, r)
+	require.Nil(t, err)
+
+	var suites junitTestSuites
+	err = xml.Unmarshal(buf.Bytes(), &suites)
+	require.Nil(t, err)
+	assert.Equal(t, "", suites.Suites[0].TestCases[0].SystemErr)
+	assert.Equal(t, "finding: offset 0: response is a list, not a dictionary\nfinding: offset 5: 1 bytes of trailing data\n",
+		suites.Suites[0].TestCases[1].SystemErr)
+}
+
+func TestWriteJUnitMultiple(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := WriteJUnit(buf, testReport(), testReport())
+	require.Nil(t, err)
+
+	var suites junitTestSuites
+	err = xml.Unmarshal(buf.Bytes(), &suites)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(suites.Suites))
+	assert.Equal(t, suites.Suites[0], suites.Suites[1])
+}
@@ -0,0 +1,138 @@
+// Package report provides machine-readable representations of the results of
+// tracker test runs.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/tests"
+)
+
+// Protocols a tracker can be tested with.
+const (
+	ProtocolHTTP = "http"
+	ProtocolUDP  = "udp"
+)
+
+// Report represents the results of testing a single tracker.
+//
+// Exactly one of HTTP and UDP is set, depending on Protocol.
+type Report struct {
+	PokeVersion string            `json:"pokeVersion"`
+	Tracker     string            `json:"tracker"`
+	Protocol    string            `json:"protocol"`
+	HTTP        *tests.HTTPResult `json:"http,omitempty"`
+	UDP         *tests.UDPResult  `json:"udp,omitempty"`
+}
+
+// NewHTTPReport creates a Report for the results of testing an HTTP tracker.
+func NewHTTPReport(tracker string, res *tests.HTTPResult) Report {
+	return Report{
+		PokeVersion: poke.Version,
+		Tracker:     tracker,
+		Protocol:    ProtocolHTTP,
+		HTTP:        res,
+	}
+}
+
+// NewUDPReport creates a Report for the results of testing a UDP tracker.
+func NewUDPReport(tracker string, res *tests.UDPResult) Report {
+	return Report{
+		PokeVersion: poke.Version,
+		Tracker:     tracker,
+		Protocol:    ProtocolUDP,
+		UDP:         res,
+	}
+}
+
+// TrackerResult returns the protocol-independent results of the Report.
+func (r Report) TrackerResult() tests.TrackerResult {
+	switch {
+	case r.HTTP != nil:
+		return r.HTTP.TrackerResult
+	case r.UDP != nil:
+		return r.UDP.TrackerResult
+	}
+	return tests.TrackerResult{}
+}
+
+// Summary summarizes the results of testing a single tracker.
+//
+// Duration is encoded as nanoseconds in JSON.
+type Summary struct {
+	Tracker  string        `json:"tracker"`
+	Protocol string        `json:"protocol"`
+	Passed   int           `json:"passed"`
+	Failed   int           `json:"failed"`
+	NotRun   int           `json:"notRun"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Summary summarizes the Report.
+func (r Report) Summary() Summary {
+	s := Summary{
+		Tracker:  r.Tracker,
+		Protocol: r.Protocol,
+	}
+
+	for _, t := range r.TrackerResult().Tests {
+		switch {
+		case !t.Run:
+			s.NotRun++
+		case t.Result.Err != nil:
+			s.Failed++
+		default:
+			s.Passed++
+		}
+		s.Duration += t.Duration
+	}
+
+	return s
+}
+
+// Combined represents the results of testing multiple trackers.
+type Combined struct {
+	Reports []Report  `json:"reports"`
+	Summary []Summary `json:"summary"`
+}
+
+// NewCombined combines the Reports.
+func NewCombined(rs []Report) Combined {
+	c := Combined{
+		Reports: rs,
+		Summary: make([]Summary, 0, len(rs)),
+	}
+	for _, r := range rs {
+		c.Summary = append(c.Summary, r.Summary())
+	}
+	return c
+}
+
+// WriteJSON writes the Report to w as indented JSON.
+func WriteJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCombinedJSON writes the Combined results to w as indented JSON.
+func WriteCombinedJSON(w io.Writer, c Combined) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// formatSeconds formats a duration as fractional seconds.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// formatResult formats the result value of a test.
+func formatResult(v interface{}) string {
+	return fmt.Sprint(v)
+}
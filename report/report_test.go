@@ -0,0 +1,90 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/tests"
+)
+
+func testReport() Report {
+	res := &tests.HTTPResult{
+		TrackerResult: tests.TrackerResult{
+			Seed:           42,
+			SupportsScrape: true,
+			Tests: []tests.Test{
+				{
+					Name:     "passing",
+					Run:      true,
+					Result:   tests.TestResult{Result: true},
+					Duration: time.Second,
+				},
+				{
+					Name:   "failing",
+					Run:    true,
+					Result: tests.TestResult{Err: errors.New("some error")},
+				},
+				{
+					Name:         "notRun",
+					NotRunReason: "some reason",
+				},
+			},
+		},
+		SupportsCompact: true,
+	}
+
+	return NewHTTPReport("http://localhost/announce", res)
+}
+
+func TestWriteJSON(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := WriteJSON(buf, testReport())
+	require.Nil(t, err)
+
+	var m map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &m)
+	require.Nil(t, err)
+	assert.Equal(t, poke.Version, m["pokeVersion"])
+	assert.Equal(t, "http://localhost/announce", m["tracker"])
+	assert.Equal(t, ProtocolHTTP, m["protocol"])
+	assert.Nil(t, m["udp"])
+
+	var r Report
+	err = json.Unmarshal(buf.Bytes(), &r)
+	require.Nil(t, err)
+	require.NotNil(t, r.HTTP)
+	assert.True(t, r.HTTP.SupportsCompact)
+	assert.True(t, r.HTTP.SupportsScrape)
+
+	ts := r.TrackerResult().Tests
+	require.Equal(t, 3, len(ts))
+	assert.Equal(t, "passing", ts[0].Name)
+	assert.Equal(t, true, ts[0].Result.Result)
+	assert.Nil(t, ts[0].Result.Err)
+	assert.Equal(t, time.Second, ts[0].Duration)
+	assert.Equal(t, "some error", ts[1].Result.Err.Error())
+	assert.False(t, ts[2].Run)
+	assert.Equal(t, "some reason", ts[2].NotRunReason)
+}
+
+func TestSummary(t *testing.T) {
+	s := testReport().Summary()
+	assert.Equal(t, Summary{
+		Tracker:  "http://localhost/announce",
+		Protocol: ProtocolHTTP,
+		Passed:   1,
+		Failed:   1,
+		NotRun:   1,
+		Duration: time.Second,
+	}, s)
+
+	c := NewCombined([]Report{testReport(), testReport()})
+	assert.Equal(t, []Summary{s, s}, c.Summary)
+}
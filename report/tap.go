@@ -0,0 +1,68 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTAP writes the Reports to w in the Test Anything Protocol, version 13.
+//
+// Tests that were not run are reported with a SKIP directive, tests that
+// returned an error as not ok, with the error in a YAML diagnostic block.
+// Findings of a test are written as comments after it.
+// The tests of all Reports are numbered consecutively. If there is more than
+// one Report, test names are prefixed with the tracker.
+func WriteTAP(w io.Writer, rs ...Report) error {
+	bw := bufio.NewWriter(w)
+
+	var total int
+	for _, r := range rs {
+		total += len(r.TrackerResult().Tests)
+	}
+
+	fmt.Fprintln(bw, "TAP version 13")
+	if len(rs) != 1 {
+		fmt.Fprintf(bw, "1..%d\n", total)
+	}
+
+	var i int
+	for _, r := range rs {
+		res := r.TrackerResult()
+		fmt.Fprintf(bw, "# poke %s, %s tracker %s\n", r.PokeVersion, r.Protocol, r.Tracker)
+		fmt.Fprintf(bw, "# seed %d\n", res.Seed)
+		if len(rs) == 1 {
+			fmt.Fprintf(bw, "1..%d\n", total)
+		}
+
+		for _, t := range res.Tests {
+			i++
+			name := t.Name
+			if len(rs) != 1 {
+				name = r.Tracker + " " + name
+			}
+
+			switch {
+			case !t.Run:
+				fmt.Fprintf(bw, "ok %d - %s # SKIP %s\n", i, name, t.NotRunReason)
+			case t.Result.Err != nil:
+				fmt.Fprintf(bw, "not ok %d - %s\n", i, name)
+				fmt.Fprintln(bw, "  ---")
+				fmt.Fprintf(bw, "  message: %q\n", t.Result.Err.Error())
+				fmt.Fprintf(bw, "  duration_s: %s\n", formatSeconds(t.Duration))
+				fmt.Fprintln(bw, "  ...")
+			default:
+				fmt.Fprintf(bw, "ok %d - %s\n", i, name)
+				if t.Result.Result != nil {
+					fmt.Fprintf(bw, "# result: %s\n", strings.Replace(formatResult(t.Result.Result), "\n", " ", -1))
+				}
+			}
+			for _, f := range t.Result.Findings {
+				fmt.Fprintf(bw, "# finding: %s\n", f)
+			}
+		}
+	}
+
+	return bw.Flush()
+}
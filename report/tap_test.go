@@ -0,0 +1,56 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+)
+
+func TestWriteTAP(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := WriteTAP(buf, testReport())
+	require.Nil(t, err)
+
+	expected := `TAP version 13
+# poke ` + poke.Version + `, http tracker http://localhost/announce
+# seed 42
+1..3
+ok 1 - passing
+# result: true
+not ok 2 - failing
+  ---
+  message: "some error"
+  duration_s: 0.000
+  ...
+ok 3 - notRun # SKIP some reason
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteTAPFindings(t *testing.T) {
+	r := testReport()
+	r.HTTP.Tests[0].Result.Findings = []http.Finding{{Offset: 3, Message: "integer with leading zero"}}
+
+	buf := bytes.NewBuffer(nil)
+	err := WriteTAP(buf, r)
+	require.Nil(t, err)
+	assert.Contains(t, buf.String(), "ok 1 - passing\n# result: true\n# finding: offset 3: integer with leading zero\nnot ok 2")
+}
+
+func TestWriteTAPMultiple(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := WriteTAP(buf, testReport(), testReport())
+	require.Nil(t, err)
+
+	s := buf.String()
+	assert.True(t, strings.HasPrefix(s, "TAP version 13\n1..6\n# poke "), s)
+	assert.Contains(t, s, "ok 1 - http://localhost/announce passing\n")
+	assert.Contains(t, s, "ok 6 - http://localhost/announce notRun # SKIP some reason\n")
+	assert.Equal(t, 2, strings.Count(s, "# seed 42\n"))
+}
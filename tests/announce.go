@@ -1,224 +1,194 @@
 package tests
 
 import (
+	"encoding/json"
 	"errors"
 	"log"
-	"math/rand"
 	"time"
 
 	"github.com/mrd0ll4r/poke"
 	"github.com/mrd0ll4r/poke/http"
-	"github.com/mrd0ll4r/poke/udp"
 )
 
 // Test represents a test performed on a tracker.
+//
+// Duration is encoded as nanoseconds in JSON.
 type Test struct {
-	Name         string
-	Run          bool
-	NotRunReason string
-	Result       TestResult
+	Name         string        `json:"name"`
+	Run          bool          `json:"run"`
+	NotRunReason string        `json:"notRunReason,omitempty"`
+	Result       TestResult    `json:"result"`
+	Duration     time.Duration `json:"duration"`
 }
 
 // TestResult represents the result of a test.
+//
+// Findings are the deviations from canonical bencode found in the responses
+// of an HTTP tracker during the test.
 type TestResult struct {
-	Result interface{}
-	Err    error
+	Result   interface{}
+	Err      error
+	Findings []http.Finding
 }
 
-// HTTPResult represents the result of all tests performed on an HTTP tracker.
-type HTTPResult struct {
-	TrackerResult
-	SupportsCompact    bool
-	SupportsNonCompact bool
-}
-
-// TrackerResult represents the result of all tests performed on a tracker.
-type TrackerResult struct {
-	SupportsAnnouncingPeerNotInPeerList bool
-	SupportsIPSpoofing                  bool
-	SupportsOptimizedSeederResponse     bool
-	Tests                               []Test
-}
-
-// UDPResult represents the result of all tests performed on a UDP tracker.
-type UDPResult struct {
-	TrackerResult
+// testResultJSON is the JSON representation of a TestResult.
+type testResultJSON struct {
+	Result   interface{}    `json:"result"`
+	Err      string         `json:"error,omitempty"`
+	Findings []http.Finding `json:"findings,omitempty"`
 }
 
-// TestUDPTracker runs tests on a UDP tracker to determine its functionality
-// and feature-completeness.
-func TestUDPTracker(addr string) (*UDPResult, error) {
-	toReturn := &UDPResult{
-		TrackerResult: TrackerResult{
-			Tests: make([]Test, 0),
-		},
+// MarshalJSON implements json.Marshaler.
+// The error is encoded as its message.
+func (r TestResult) MarshalJSON() ([]byte, error) {
+	j := testResultJSON{
+		Result:   r.Result,
+		Findings: r.Findings,
 	}
-
-	f := func() (poke.Announcer, error) {
-		c, err := udp.NewClient(addr)
-		if err != nil {
-			return nil, err
-		}
-		return c, nil
-	}
-
-	err := runAll(f, &toReturn.TrackerResult)
-	if err != nil {
-		return nil, err
+	if r.Err != nil {
+		j.Err = r.Err.Error()
 	}
-
-	return toReturn, nil
+	return json.Marshal(j)
 }
 
-func testTrackerSupportsAnnouncingPeerNotInPeerList(c poke.Announcer, result *TrackerResult) error {
-	t := Test{
-		Name: "trackerSupportsAnnouncingPeerNotInPeerListAnnounce",
-	}
-
-	res, err := trackerSupportsAnnouncingPeerNotInPeerListAnnounce(c)
-	t.Run = true
-	t.Result.Err = err
-	t.Result.Result = res
+// UnmarshalJSON implements json.Unmarshaler.
+// The error is restored from its message, so it can not be compared to the
+// original error.
+func (r *TestResult) UnmarshalJSON(b []byte) error {
+	var j testResultJSON
+	err := json.Unmarshal(b, &j)
 	if err != nil {
-		result.SupportsAnnouncingPeerNotInPeerList = false
-	} else {
-		result.SupportsAnnouncingPeerNotInPeerList = res
+		return err
 	}
-	result.Tests = append(result.Tests, t)
 
+	r.Result = j.Result
+	r.Findings = j.Findings
+	r.Err = nil
+	if j.Err != "" {
+		r.Err = errors.New(j.Err)
+	}
 	return nil
 }
 
-func testTrackerSupportsIPSpoofing(c poke.Announcer, result *TrackerResult) error {
-	t := Test{
-		Name: "trackerSupportsIPSpoofingAnnounce",
-	}
-
-	res, err := trackerSupportsIPSpoofingAnnounce(c, result.SupportsAnnouncingPeerNotInPeerList)
-	t.Run = true
-	t.Result.Err = err
-	t.Result.Result = res
-	if err != nil {
-		result.SupportsIPSpoofing = false
-	} else {
-		result.SupportsIPSpoofing = res
-	}
-	result.Tests = append(result.Tests, t)
+// HTTPResult represents the result of all tests performed on an HTTP tracker.
+type HTTPResult struct {
+	TrackerResult
+	SupportsCompact    bool `json:"supportsCompact"`
+	SupportsNonCompact bool `json:"supportsNonCompact"`
+	HonoursNoPeerID    bool `json:"honoursNoPeerID"`
+}
 
-	return nil
+// TrackerResult represents the result of all tests performed on a tracker.
+//
+// Seed is the seed of the Generator used for the tests. Running the tests
+// with a Generator with the same seed sends the same requests.
+type TrackerResult struct {
+	Seed                                int64  `json:"seed"`
+	SupportsAnnouncingPeerNotInPeerList bool   `json:"supportsAnnouncingPeerNotInPeerList"`
+	SupportsIPSpoofing                  bool   `json:"supportsIPSpoofing"`
+	SupportsOptimizedSeederResponse     bool   `json:"supportsOptimizedSeederResponse"`
+	SupportsScrape                      bool   `json:"supportsScrape"`
+	SupportsIPv6                        bool   `json:"supportsIPv6"`
+	IdentifiesPeersByKey                bool   `json:"identifiesPeersByKey"`
+	Tests                               []Test `json:"tests"`
 }
 
-func testTrackerSupportsOptimizedSeederResponse(c poke.Announcer, result *TrackerResult) error {
-	t := Test{
-		Name: "trackerSupportsOptimizedSeederResponseAnnounce",
-	}
-	res, err := trackerSupportsOptimizedSeederAnnounce(c)
-	t.Run = true
-	t.Result.Err = err
-	t.Result.Result = res
-	if err != nil {
-		result.SupportsOptimizedSeederResponse = false
-	} else {
-		result.SupportsOptimizedSeederResponse = res
-	}
-	result.Tests = append(result.Tests, t)
+// UDPResult represents the result of all tests performed on a UDP tracker.
+type UDPResult struct {
+	TrackerResult
+	RejectsForgedConnectionIDs  bool `json:"rejectsForgedConnectionIDs"`
+	SupportsConnectionIDReuse   bool `json:"supportsConnectionIDReuse"`
+	BindsConnectionIDsToAddress bool `json:"bindsConnectionIDsToAddress"`
+	ExpiresConnectionIDs        bool `json:"expiresConnectionIDs"`
+	HonoursURLData              bool `json:"honoursURLData"`
+}
 
-	return nil
+// ipv6Announcer is implemented by Announcers that know whether they talk to
+// the tracker via IPv6.
+type ipv6Announcer interface {
+	IPv6() bool
 }
 
-func runBasicAnnounce(c poke.Announcer, result *TrackerResult) error {
-	t := Test{
-		Name: "basicAnnounce",
+// newPeer generates a new peer with an address of the family the Announcer
+// uses to talk to the tracker.
+func newPeer(c poke.Announcer, g *poke.Generator) poke.Peer {
+	if a, ok := c.(ipv6Announcer); ok && a.IPv6() {
+		return g.Peer6()
 	}
-	err := basicAnnounce(c, result.SupportsAnnouncingPeerNotInPeerList)
-	t.Run = true
-	t.Result.Err = err
-	result.Tests = append(result.Tests, t)
-
-	return nil
+	return g.Peer()
 }
 
-func runAll(f func() (poke.Announcer, error), result *TrackerResult) error {
-	c, err := f()
-	if err != nil {
-		return err
-	}
-	err = testTrackerSupportsAnnouncingPeerNotInPeerList(c, result)
-	if err != nil {
-		return err
+// TestUDPTracker runs tests on a UDP tracker to determine its functionality
+// and feature-completeness.
+func TestUDPTracker(addr string, cfg Config) (*UDPResult, error) {
+	cfg = cfg.withGenerator()
+	toReturn := &UDPResult{
+		TrackerResult: TrackerResult{
+			Seed:  cfg.Generator.Seed(),
+			Tests: make([]Test, 0),
+		},
 	}
 
-	err = testTrackerSupportsIPSpoofing(c, result)
+	c, err := cfg.newUDPClient(addr)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer c.Close()
 
-	err = testTrackerSupportsOptimizedSeederResponse(c, result)
-	if err != nil {
-		return err
-	}
+	s := newSuite(ProtocolUDP, addr, cfg, func() (poke.Announcer, error) {
+		return c, nil
+	})
+	s.caps[CapabilityAnnounce] = true
+	s.run(&toReturn.TrackerResult)
 
-	err = runBasicAnnounce(c, result)
+	toReturn.RejectsForgedConnectionIDs = s.caps[CapabilityRejectsForgedConnectionIDs]
+	toReturn.SupportsConnectionIDReuse = s.caps[CapabilityConnectionIDReuse]
+	toReturn.BindsConnectionIDsToAddress = s.caps[CapabilityConnectionIDBoundToAddress]
+	toReturn.ExpiresConnectionIDs = s.caps[CapabilityConnectionIDExpiry]
+	toReturn.HonoursURLData = s.caps[CapabilityURLData]
 
-	return err
+	return toReturn, nil
 }
 
 // TestHTTPTracker runs tests on an HTTP tracker to determine its functionality
 // and feature-completeness.
-func TestHTTPTracker(announceURI string) (*HTTPResult, error) {
+func TestHTTPTracker(announceURI string, cfg Config) (*HTTPResult, error) {
+	cfg = cfg.withGenerator()
 	toReturn := &HTTPResult{
 		TrackerResult: TrackerResult{
+			Seed:  cfg.Generator.Seed(),
 			Tests: make([]Test, 0),
 		},
 	}
-	t := Test{
-		Name: "trackerSupportsCompactAnnounce",
-		Run:  true,
-	}
-	supportsCompact, err := trackerSupportsCompactHTTPAnnounce(announceURI)
-	t.Result.Err = err
-	t.Result.Result = supportsCompact
-	if err != nil {
-		toReturn.SupportsCompact = false
-	} else {
-		toReturn.SupportsCompact = supportsCompact
-	}
-	toReturn.Tests = append(toReturn.Tests, t)
 
-	t = Test{
-		Name: "trackerSupportsNonCompactAnnounce",
-		Run:  true,
-	}
-	supportsNonCompact, err := trackerSupportsNonCompactHTTPAnnounce(announceURI)
-	t.Result.Err = err
-	t.Result.Result = supportsNonCompact
-	if err != nil {
-		toReturn.SupportsNonCompact = false
-	} else {
-		toReturn.SupportsNonCompact = supportsNonCompact
-	}
-	toReturn.Tests = append(toReturn.Tests, t)
+	runHTTPSuite(ProtocolHTTP, announceURI, cfg, toReturn)
 
-	if !toReturn.SupportsCompact && !toReturn.SupportsNonCompact {
-		// We cannot run tests.
-		return toReturn, nil
-	}
+	return toReturn, nil
+}
+
+// runHTTPSuite runs the tests for the protocol on an HTTP tracker.
+// The tests share a client that uses compact announces if the tracker
+// supports them.
+func runHTTPSuite(p Protocol, announceURI string, cfg Config, result *HTTPResult) {
+	cfg.findings = &findingsRecorder{}
 
-	f := func() (poke.Announcer, error) {
-		c, err := http.NewClient(announceURI)
+	var s *suite
+	s = newSuite(p, announceURI, cfg, func() (poke.Announcer, error) {
+		c, err := cfg.newHTTPClient(announceURI)
 		if err != nil {
 			return nil, err
 		}
-		c.OverrideCompact(toReturn.SupportsCompact)
+		c.OverrideCompact(s.caps[CapabilityCompact])
+		if p == ProtocolHTTPIPv6 {
+			return ipv6HTTPClient{c}, nil
+		}
 		return c, nil
-	}
-
-	err = runAll(f, &toReturn.TrackerResult)
-	if err != nil {
-		return nil, err
-	}
+	})
+	s.run(&result.TrackerResult)
 
-	return toReturn, nil
+	result.SupportsCompact = s.caps[CapabilityCompact]
+	result.SupportsNonCompact = s.caps[CapabilityNonCompact]
+	result.HonoursNoPeerID = s.caps[CapabilityNoPeerID]
 }
 
 // BasicHTTPCompactAnnounce performs a basic, compact HTTP announce.
@@ -228,7 +198,7 @@ func BasicHTTPCompactAnnounce(announceURI string, trackerSupportsAnnouncingPeerN
 		return err
 	}
 	c.OverrideCompact(true)
-	return basicAnnounce(c, trackerSupportsAnnouncingPeerNotInPeerList)
+	return basicAnnounce(c, newGenerator(), trackerSupportsAnnouncingPeerNotInPeerList)
 }
 
 // BasicHTTPNonCompactAnnounce performs a basic, non-compact HTTP announce.
@@ -238,12 +208,12 @@ func BasicHTTPNonCompactAnnounce(announceURI string, trackerSupportsAnnouncingPe
 		return err
 	}
 	c.OverrideCompact(false)
-	return basicAnnounce(c, trackerSupportsAnnouncingPeerNotInPeerList)
+	return basicAnnounce(c, newGenerator(), trackerSupportsAnnouncingPeerNotInPeerList)
 }
 
 // BasicHTTPSeederAnnounce performs a basic HTTP announce as a seeder.
 func BasicHTTPSeederAnnounce(announceURI string) error {
-	return basicHTTPSeederAnnounce(announceURI)
+	return basicHTTPSeederAnnounce(announceURI, newGenerator())
 }
 
 // TrackerSupportsIPSpoofingHTTPNonCompactAnnounce reports whether the tracker
@@ -254,7 +224,7 @@ func TrackerSupportsIPSpoofingHTTPNonCompactAnnounce(announceURI string, tracker
 		return false, err
 	}
 	c.OverrideCompact(false)
-	return trackerSupportsIPSpoofingAnnounce(c, trackerSupportsOptimizedPeerList)
+	return trackerSupportsIPSpoofingAnnounce(c, newGenerator(), trackerSupportsOptimizedPeerList)
 }
 
 // TrackerSupportsIPSpoofingHTTPCompactAnnounce reports whether the tracker
@@ -265,19 +235,19 @@ func TrackerSupportsIPSpoofingHTTPCompactAnnounce(announceURI string, trackerSup
 		return false, err
 	}
 	c.OverrideCompact(true)
-	return trackerSupportsIPSpoofingAnnounce(c, trackerSupportsOptimizedPeerList)
+	return trackerSupportsIPSpoofingAnnounce(c, newGenerator(), trackerSupportsOptimizedPeerList)
 }
 
 // TrackerSupportsCompactHTTPAnnounce reports whether the tracker supports
 // compact HTTP announces.
 func TrackerSupportsCompactHTTPAnnounce(announceURI string) (bool, error) {
-	return trackerSupportsCompactHTTPAnnounce(announceURI)
+	return trackerSupportsCompactHTTPAnnounce(announceURI, DefaultConfig().withGenerator())
 }
 
 // TrackerSupportsNonCompactHTTPAnnounce reports whether the tracker supports
 // non-compact HTTP announces.
 func TrackerSupportsNonCompactHTTPAnnounce(announceURI string) (bool, error) {
-	return trackerSupportsNonCompactHTTPAnnounce(announceURI)
+	return trackerSupportsNonCompactHTTPAnnounce(announceURI, DefaultConfig().withGenerator())
 }
 
 // TrackerSupportsOptimizedSeederHTTPAnnounce reports whether the tracker
@@ -289,7 +259,7 @@ func TrackerSupportsOptimizedSeederHTTPAnnounce(announceURI string, trackerSuppo
 		return false, err
 	}
 	c.OverrideCompact(trackerSupportsCompactAnnounce)
-	return trackerSupportsOptimizedSeederAnnounce(c)
+	return trackerSupportsOptimizedSeederAnnounce(c, newGenerator())
 }
 
 // TrackerSupportsAnnouncingPeerNotInPeerListHTTPCompactAnnounce reports
@@ -301,7 +271,7 @@ func TrackerSupportsAnnouncingPeerNotInPeerListHTTPCompactAnnounce(announceURI s
 		return false, err
 	}
 	c.OverrideCompact(trackerSupportsCompactAnnounce)
-	return trackerSupportsAnnouncingPeerNotInPeerListAnnounce(c)
+	return trackerSupportsAnnouncingPeerNotInPeerListAnnounce(c, newGenerator())
 }
 
 // CheckReturnedPeersHTTPAnnounce checks whether the peers returned by an
@@ -311,49 +281,77 @@ func CheckReturnedPeersHTTPAnnounce(announceURI string) error {
 	if err != nil {
 		return err
 	}
-	return checkReturnedPeersAnnounce(c)
+	return checkReturnedPeersAnnounce(c, newGenerator())
 }
 
 // InvalidShortInfohashHTTPAnnounce checks whether the tracker rejects announces
 // with an invalid too short infohash.
 func InvalidShortInfohashHTTPAnnounce(announceURI string) error {
-	return invalidShortInfohashHTTPAnnounce(announceURI)
+	c, err := http.NewClient(announceURI)
+	if err != nil {
+		return err
+	}
+	return invalidShortInfohashAnnounce(c, newGenerator())
 }
 
 // InvalidLongInfohashHTTPAnnounce checks whether the tracker rejects announces
 // with an invalid too long infohash.
 func InvalidLongInfohashHTTPAnnounce(announceURI string) error {
-	return invalidLongInfohashHTTPAnnounce(announceURI)
+	c, err := http.NewClient(announceURI)
+	if err != nil {
+		return err
+	}
+	return invalidLongInfohashAnnounce(c, newGenerator())
 }
 
 // InvalidShortPeerIDHTTPAnnounce checks whether the tracker rejects announces
 // with an invalid too short peer ID.
 func InvalidShortPeerIDHTTPAnnounce(announceURI string) error {
-	return invalidShortPeerIDHTTPAnnounce(announceURI)
+	c, err := http.NewClient(announceURI)
+	if err != nil {
+		return err
+	}
+	return invalidShortPeerIDAnnounce(c, newGenerator())
 }
 
 // InvalidLongPeerIDHTTPAnnounce checks whether the tracker rejects announces
 // with an invalid too short peer ID.
 func InvalidLongPeerIDHTTPAnnounce(announceURI string) error {
-	return invalidLongPeerIDHTTPAnnounce(announceURI)
+	c, err := http.NewClient(announceURI)
+	if err != nil {
+		return err
+	}
+	return invalidLongPeerIDAnnounce(c, newGenerator())
 }
 
 // InvalidNegativeUploadedHTTPAnnounce checks whether the tracker rejects
 // announces with an invalid negative amount of bytes uploaded.
 func InvalidNegativeUploadedHTTPAnnounce(announceURI string) error {
-	return invalidNegativeUploadedHTTPAnnounce(announceURI)
+	c, err := http.NewClient(announceURI)
+	if err != nil {
+		return err
+	}
+	return invalidNegativeUploadedAnnounce(c, newGenerator())
 }
 
 // InvalidNegativeDownloadedHTTPAnnounce checks whether the tracker rejects
 // announces with an invalid negative amount of bytes downloaded.
 func InvalidNegativeDownloadedHTTPAnnounce(announceURI string) error {
-	return invalidNegativeDownloadedHTTPAnnounce(announceURI)
+	c, err := http.NewClient(announceURI)
+	if err != nil {
+		return err
+	}
+	return invalidNegativeDownloadedAnnounce(c, newGenerator())
 }
 
 // InvalidNegativeLeftHTTPAnnounce checks whether the tracker rejects
 // announces with an invalid negative amount of bytes left.
 func InvalidNegativeLeftHTTPAnnounce(announceURI string) error {
-	return invalidNegativeLeftHTTPAnnounce(announceURI)
+	c, err := http.NewClient(announceURI)
+	if err != nil {
+		return err
+	}
+	return invalidNegativeLeftAnnounce(c, newGenerator())
 }
 
 // InvalidEventHTTPAnnounce checks whether the tracker rejects announces with
@@ -363,16 +361,16 @@ func InvalidEventHTTPAnnounce(announceURI string) error {
 	if err != nil {
 		return err
 	}
-	return invalidEventAnnounce(c)
+	return invalidEventAnnounce(c, newGenerator())
 }
 
-func basicAnnounce(c poke.Announcer, trackerSupportsAnnouncingPeerNotInPeerList bool) error {
+func basicAnnounce(c poke.Announcer, g *poke.Generator, trackerSupportsAnnouncingPeerNotInPeerList bool) error {
 	if poke.Debug {
 		log.Println("Running basicAnnounce")
 	}
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
 		Event:    poke.EventStarted,
 		Numwant:  50,
 		Left:     100,
@@ -407,17 +405,18 @@ func basicAnnounce(c poke.Announcer, trackerSupportsAnnouncingPeerNotInPeerList
 	return nil
 }
 
-func trackerSupportsCompactHTTPAnnounce(announceURI string) (bool, error) {
-	c, err := http.NewClient(announceURI)
+func trackerSupportsCompactHTTPAnnounce(announceURI string, cfg Config) (bool, error) {
+	g := cfg.Generator
+	c, err := cfg.newHTTPClient(announceURI)
 	if err != nil {
 		return false, poke.WrapError("unable to create client", err)
 	}
 
-	leecher1 := poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano())))
-	leecher2 := poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano())))
+	leecher1 := g.Peer()
+	leecher2 := g.Peer()
 
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
 		Peer:     leecher1,
 		Event:    poke.EventStarted,
 		Numwant:  50,
@@ -464,17 +463,18 @@ func trackerSupportsCompactHTTPAnnounce(announceURI string) (bool, error) {
 	return false, nil
 }
 
-func trackerSupportsNonCompactHTTPAnnounce(announceURI string) (bool, error) {
-	c, err := http.NewClient(announceURI)
+func trackerSupportsNonCompactHTTPAnnounce(announceURI string, cfg Config) (bool, error) {
+	g := cfg.Generator
+	c, err := cfg.newHTTPClient(announceURI)
 	if err != nil {
 		return false, poke.WrapError("unable to create client", err)
 	}
 
-	leecher1 := poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano())))
-	leecher2 := poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano())))
+	leecher1 := g.Peer()
+	leecher2 := g.Peer()
 
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
 		Peer:     leecher1,
 		Event:    poke.EventStarted,
 		Numwant:  50,
@@ -521,15 +521,15 @@ func trackerSupportsNonCompactHTTPAnnounce(announceURI string) (bool, error) {
 	return false, nil
 }
 
-func basicHTTPSeederAnnounce(announceURI string) error {
+func basicHTTPSeederAnnounce(announceURI string, g *poke.Generator) error {
 	c, err := http.NewClient(announceURI)
 	if err != nil {
 		return poke.WrapError("unable to create client", err)
 	}
 
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
+		Peer:     g.Peer(),
 		Event:    poke.EventStarted,
 		Numwant:  50,
 		Compact:  true,
@@ -558,13 +558,13 @@ func basicHTTPSeederAnnounce(announceURI string) error {
 	return nil
 }
 
-func trackerSupportsAnnouncingPeerNotInPeerListAnnounce(c poke.Announcer) (bool, error) {
+func trackerSupportsAnnouncingPeerNotInPeerListAnnounce(c poke.Announcer, g *poke.Generator) (bool, error) {
 	if poke.Debug {
 		log.Println("Running trackerSupportsAnnouncingPeerNotInPeerList")
 	}
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
 		Event:    poke.EventStarted,
 		Numwant:  50,
 		Left:     100,
@@ -613,16 +613,15 @@ func trackerSupportsAnnouncingPeerNotInPeerListAnnounce(c poke.Announcer) (bool,
 	return false, nil
 }
 
-func trackerSupportsIPSpoofingAnnounce(c poke.Announcer, trackerSupportsAnnouncingPeerNotInPeerList bool) (bool, error) {
+func trackerSupportsIPSpoofingAnnounce(c poke.Announcer, g *poke.Generator, trackerSupportsAnnouncingPeerNotInPeerList bool) (bool, error) {
 	if poke.Debug {
 		log.Println("Running trackerSupportsIPSpoofingAnnounce")
 	}
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	leecher1 := poke.NewPeer(r)
-	leecher2 := poke.NewPeer(r)
+	leecher1 := newPeer(c, g)
+	leecher2 := newPeer(c, g)
 
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(r),
+		InfoHash: g.InfoHash(),
 		Peer:     leecher1,
 		Event:    poke.EventStarted,
 		Numwant:  50,
@@ -677,14 +676,13 @@ func trackerSupportsIPSpoofingAnnounce(c poke.Announcer, trackerSupportsAnnounci
 	return false, nil
 }
 
-func trackerSupportsOptimizedSeederAnnounce(c poke.Announcer) (bool, error) {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	leecher1 := poke.NewPeer(r)
-	seeder1 := poke.NewPeer(r)
-	seeder2 := poke.NewPeer(r)
+func trackerSupportsOptimizedSeederAnnounce(c poke.Announcer, g *poke.Generator) (bool, error) {
+	leecher1 := newPeer(c, g)
+	seeder1 := newPeer(c, g)
+	seeder2 := newPeer(c, g)
 
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(r),
+		InfoHash: g.InfoHash(),
 		Peer:     leecher1,
 		Event:    poke.EventStarted,
 		Numwant:  50,
@@ -745,15 +743,14 @@ func trackerSupportsOptimizedSeederAnnounce(c poke.Announcer) (bool, error) {
 	return false, nil
 }
 
-func checkReturnedPeersAnnounce(c poke.Announcer) error {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	leecher1 := poke.NewPeer(r)
-	leecher2 := poke.NewPeer(r)
-	seeder1 := poke.NewPeer(r)
-	seeder2 := poke.NewPeer(r)
+func checkReturnedPeersAnnounce(c poke.Announcer, g *poke.Generator) error {
+	leecher1 := newPeer(c, g)
+	leecher2 := newPeer(c, g)
+	seeder1 := newPeer(c, g)
+	seeder2 := newPeer(c, g)
 
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(r),
+		InfoHash: g.InfoHash(),
 		Peer:     leecher1,
 		Event:    poke.EventStarted,
 		Numwant:  50,
@@ -857,15 +854,10 @@ func checkReturnedPeersAnnounce(c poke.Announcer) error {
 	return nil
 }
 
-func invalidShortInfohashHTTPAnnounce(announceURI string) error {
-	c, err := http.NewClient(announceURI)
-	if err != nil {
-		return poke.WrapError("unable to create client", err)
-	}
-
+func invalidShortInfohashAnnounce(c poke.Announcer, g *poke.Generator) error {
 	req := poke.AnnounceRequest{
 		InfoHash: poke.InfoHash([]byte{30, 30, 30}),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		Peer:     newPeer(c, g),
 		Event:    poke.EventStarted,
 		Numwant:  50,
 		Compact:  true,
@@ -884,18 +876,13 @@ func invalidShortInfohashHTTPAnnounce(announceURI string) error {
 	}
 }
 
-func invalidLongInfohashHTTPAnnounce(announceURI string) error {
-	c, err := http.NewClient(announceURI)
-	if err != nil {
-		return poke.WrapError("unable to create client", err)
-	}
-
+func invalidLongInfohashAnnounce(c poke.Announcer, g *poke.Generator) error {
 	req := poke.AnnounceRequest{
 		InfoHash: poke.InfoHash([]byte{
 			30, 30, 30, 30, 30, 30, 30, 30, 30, 30,
 			30, 30, 30, 30, 30, 30, 30, 30, 30, 30,
 			30}),
-		Peer:    poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		Peer:    newPeer(c, g),
 		Event:   poke.EventStarted,
 		Numwant: 50,
 		Compact: true,
@@ -914,15 +901,10 @@ func invalidLongInfohashHTTPAnnounce(announceURI string) error {
 	}
 }
 
-func invalidShortPeerIDHTTPAnnounce(announceURI string) error {
-	c, err := http.NewClient(announceURI)
-	if err != nil {
-		return poke.WrapError("unable to create client", err)
-	}
-
+func invalidShortPeerIDAnnounce(c poke.Announcer, g *poke.Generator) error {
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
 		Event:    poke.EventStarted,
 		Numwant:  50,
 		Compact:  true,
@@ -943,15 +925,10 @@ func invalidShortPeerIDHTTPAnnounce(announceURI string) error {
 	}
 }
 
-func invalidLongPeerIDHTTPAnnounce(announceURI string) error {
-	c, err := http.NewClient(announceURI)
-	if err != nil {
-		return poke.WrapError("unable to create client", err)
-	}
-
+func invalidLongPeerIDAnnounce(c poke.Announcer, g *poke.Generator) error {
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
 		Event:    poke.EventStarted,
 		Numwant:  50,
 		Compact:  true,
@@ -972,15 +949,10 @@ func invalidLongPeerIDHTTPAnnounce(announceURI string) error {
 	}
 }
 
-func invalidNegativeUploadedHTTPAnnounce(announceURI string) error {
-	c, err := http.NewClient(announceURI)
-	if err != nil {
-		return poke.WrapError("unable to create client", err)
-	}
-
+func invalidNegativeUploadedAnnounce(c poke.Announcer, g *poke.Generator) error {
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
 		Event:    poke.EventStarted,
 		Numwant:  50,
 		Compact:  true,
@@ -1000,15 +972,10 @@ func invalidNegativeUploadedHTTPAnnounce(announceURI string) error {
 	}
 }
 
-func invalidNegativeDownloadedHTTPAnnounce(announceURI string) error {
-	c, err := http.NewClient(announceURI)
-	if err != nil {
-		return poke.WrapError("unable to create client", err)
-	}
-
+func invalidNegativeDownloadedAnnounce(c poke.Announcer, g *poke.Generator) error {
 	req := poke.AnnounceRequest{
-		InfoHash:   poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:       poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash:   g.InfoHash(),
+		Peer:       newPeer(c, g),
 		Event:      poke.EventStarted,
 		Numwant:    50,
 		Compact:    true,
@@ -1028,15 +995,10 @@ func invalidNegativeDownloadedHTTPAnnounce(announceURI string) error {
 	}
 }
 
-func invalidNegativeLeftHTTPAnnounce(announceURI string) error {
-	c, err := http.NewClient(announceURI)
-	if err != nil {
-		return poke.WrapError("unable to create client", err)
-	}
-
+func invalidNegativeLeftAnnounce(c poke.Announcer, g *poke.Generator) error {
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
 		Event:    poke.EventStarted,
 		Numwant:  50,
 		Compact:  true,
@@ -1055,10 +1017,10 @@ func invalidNegativeLeftHTTPAnnounce(announceURI string) error {
 	}
 }
 
-func invalidEventAnnounce(c poke.Announcer) error {
+func invalidEventAnnounce(c poke.Announcer, g *poke.Generator) error {
 	req := poke.AnnounceRequest{
-		InfoHash: poke.NewInfohash(rand.New(rand.NewSource(time.Now().UnixNano()))),
-		Peer:     poke.NewPeer(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
 		Event:    poke.EventInvalid,
 		Numwant:  50,
 		Compact:  true,
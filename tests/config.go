@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"crypto/tls"
+	"regexp"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+	"github.com/mrd0ll4r/poke/udp"
+)
+
+// Config configures the clients used to test a tracker.
+type Config struct {
+	// Timeout is the time to wait for the response to a request.
+	// For UDP, this is the time to wait before the first retransmission.
+	// If zero, requests wait for responses without a time limit, and the UDP
+	// tests that expect the tracker to drop packets are not run.
+	Timeout time.Duration
+
+	// Retries is the number of times UDP requests are retransmitted.
+	Retries int
+
+	// ConnectionIDExpiry is the time to wait before checking that a UDP
+	// tracker no longer accepts a connection ID.
+	// BEP 15 allows trackers to accept connection IDs for up to two minutes.
+	// If zero, the check is not run, because it stalls the tests for that
+	// long. ConnectionIDExpiryBEP15 is a suitable value.
+	ConnectionIDExpiry time.Duration
+
+	// Generator generates the infohashes and peers used by the tests.
+	// If nil, a Generator seeded with the current time is used.
+	Generator *poke.Generator
+
+	// Transport, if set, is called for every client created for the tests
+	// with the client's RoundTripper. The client uses the returned
+	// RoundTripper instead, which makes it possible to record or replay the
+	// traffic of a test run.
+	Transport func(poke.RoundTripper) poke.RoundTripper
+
+	// Run, if set, selects the tests whose names match it.
+	Run *regexp.Regexp
+
+	// Tags, if not empty, selects the tests that have at least one of them.
+	Tags []Tag
+
+	// HTTP are the expectations HTTP responses are checked against by the
+	// transport tests.
+	HTTP HTTPExpectations
+
+	// TLS, if set, is the TLS configuration used to connect to HTTPS
+	// trackers.
+	TLS *tls.Config
+
+	// Private, if set, describes the tracker as private and enables the
+	// tests of passkeys and registered torrents.
+	Private *PrivateTracker
+
+	// Strict makes tests fail if a response of an HTTP tracker deviates
+	// from canonical bencode. Otherwise, the deviations are only reported
+	// as findings of the test.
+	Strict bool
+
+	// findings, if set, collects the findings of all HTTP responses.
+	findings *findingsRecorder
+}
+
+// ConnectionIDExpiryBEP15 is the time to wait for a UDP connection ID to
+// expire to check that a tracker expires them as BEP 15 requires: a little
+// more than two minutes.
+const ConnectionIDExpiryBEP15 = 2*time.Minute + 10*time.Second
+
+// DefaultConfig returns the default Config.
+func DefaultConfig() Config {
+	return Config{
+		Timeout: udp.DefaultTimeout,
+		HTTP:    DefaultHTTPExpectations(),
+	}
+}
+
+// Selects reports whether the test is selected by Run and Tags.
+// Tests that produce capabilities needed by the selected tests are run even
+// if they are not selected.
+func (c Config) Selects(d Definition) bool {
+	if c.Run != nil && !c.Run.MatchString(d.Name) {
+		return false
+	}
+	if len(c.Tags) == 0 {
+		return true
+	}
+	for _, t := range c.Tags {
+		if d.HasTag(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// newGenerator creates a Generator seeded with the current time.
+func newGenerator() *poke.Generator {
+	return poke.NewGenerator(time.Now().UnixNano())
+}
+
+// withGenerator returns a copy of the Config that has a Generator.
+func (c Config) withGenerator() Config {
+	if c.Generator == nil {
+		c.Generator = newGenerator()
+	}
+	return c
+}
+
+// newHTTPClient creates a new HTTP client configured according to the Config.
+func (c Config) newHTTPClient(announceURI string) (*http.Client, error) {
+	client, err := http.NewClient(announceURI)
+	if err != nil {
+		return nil, err
+	}
+	client.SetTimeout(c.Timeout)
+	if c.TLS != nil {
+		client.SetTLSConfig(c.TLS)
+	}
+	if c.Transport != nil {
+		client.SetRoundTripper(c.Transport(client.RoundTripper()))
+	}
+	if c.findings != nil {
+		client.SetRoundTripper(c.findings.wrap(client.RoundTripper()))
+	}
+	return client, nil
+}
+
+// newUDPClient creates a new UDP client configured according to the Config.
+func (c Config) newUDPClient(addr string) (*udp.Client, error) {
+	client, err := udp.NewClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	client.SetTimeout(c.Timeout)
+	client.SetRetries(c.Retries)
+	if c.Generator != nil {
+		client.SetTransactionID(c.Generator.Uint32())
+	}
+	if c.Transport != nil {
+		client.SetRoundTripper(c.Transport(client.RoundTripper()))
+	}
+	return client, nil
+}
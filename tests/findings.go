@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+)
+
+// findingsRecorder validates the responses of HTTP trackers and collects the
+// findings until they are taken by the test that caused them.
+type findingsRecorder struct {
+	mu       sync.Mutex
+	findings []http.Finding
+}
+
+// wrap returns a RoundTripper that validates the responses of rt.
+func (r *findingsRecorder) wrap(rt poke.RoundTripper) poke.RoundTripper {
+	return poke.RoundTripperFunc(func(ctx context.Context, request []byte) ([]byte, error) {
+		b, err := rt.RoundTrip(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		fs := http.Validate(b)
+		r.mu.Lock()
+		r.findings = append(r.findings, fs...)
+		r.mu.Unlock()
+
+		return b, nil
+	})
+}
+
+// take returns the findings collected since the last call, without
+// duplicates.
+// Tests usually perform many requests of the same kind, which would repeat
+// the same findings.
+func (r *findingsRecorder) take() []http.Finding {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var fs []http.Finding
+	seen := make(map[http.Finding]bool)
+	for _, f := range r.findings {
+		if !seen[f] {
+			seen[f] = true
+			fs = append(fs, f)
+		}
+	}
+	r.findings = nil
+
+	return fs
+}
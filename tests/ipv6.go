@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"errors"
+	"log"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+)
+
+// Results of the ipv4IPv6SwarmMixing test.
+const (
+	SwarmsMixed     = "mixed"
+	SwarmsSeparated = "separated"
+)
+
+// ipv6HTTPClient is an HTTP client that announces IPv6 peers.
+type ipv6HTTPClient struct {
+	*http.Client
+}
+
+func (ipv6HTTPClient) IPv6() bool {
+	return true
+}
+
+// TestHTTPTrackerIPv6 runs tests on an HTTP tracker using IPv6 peers.
+//
+// IPv6 peers are announced via the ip and ipv6 parameters, which means the
+// tracker has to accept IP addresses provided by the client for these tests to
+// succeed.
+func TestHTTPTrackerIPv6(announceURI string, cfg Config) (*HTTPResult, error) {
+	cfg = cfg.withGenerator()
+	toReturn := &HTTPResult{
+		TrackerResult: TrackerResult{
+			Seed:  cfg.Generator.Seed(),
+			Tests: make([]Test, 0),
+		},
+	}
+
+	runHTTPSuite(ProtocolHTTPIPv6, announceURI, cfg, toReturn)
+
+	return toReturn, nil
+}
+
+// announceTwoIPv6Leechers announces two IPv6 leechers and returns the first
+// leecher along with the first leecher as returned to the second one.
+func announceTwoIPv6Leechers(c poke.Announcer, g *poke.Generator) (poke.Peer, poke.Peer, error) {
+	leecher1 := g.Peer6()
+	leecher2 := g.Peer6()
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     leecher1,
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	_, err := announce(c, req)
+	if err != nil {
+		return poke.Peer{}, poke.Peer{}, err
+	}
+
+	req.Peer = leecher2
+
+	resp, err := announce(c, req)
+	if err != nil {
+		return poke.Peer{}, poke.Peer{}, err
+	}
+
+	for _, p := range resp.Peers {
+		if p.IsEqual(leecher1) {
+			return leecher1, p, nil
+		}
+	}
+
+	return poke.Peer{}, poke.Peer{}, errors.New("announce did not return the other known leecher")
+}
+
+// trackerSupportsIPv6HTTPAnnounce reports whether IPv6 peers announced via the
+// ip and ipv6 parameters are returned with their IPv6 address.
+// For compact announces, this means they must be returned in peers6.
+func trackerSupportsIPv6HTTPAnnounce(announceURI string, cfg Config, compact bool) (bool, error) {
+	if poke.Debug {
+		log.Println("Running trackerSupportsIPv6HTTPAnnounce")
+	}
+	c, err := cfg.newHTTPClient(announceURI)
+	if err != nil {
+		return false, poke.WrapError("unable to create client", err)
+	}
+	c.OverrideCompact(compact)
+
+	leecher1, returned, err := announceTwoIPv6Leechers(c, cfg.Generator)
+	if err != nil {
+		return false, err
+	}
+
+	return returned.IP.Equal(leecher1.IP), nil
+}
+
+func ipv6NonCompactHTTPAnnounce(announceURI string, cfg Config) error {
+	if poke.Debug {
+		log.Println("Running ipv6NonCompactHTTPAnnounce")
+	}
+	c, err := cfg.newHTTPClient(announceURI)
+	if err != nil {
+		return poke.WrapError("unable to create client", err)
+	}
+	c.OverrideCompact(false)
+
+	leecher1, returned, err := announceTwoIPv6Leechers(c, cfg.Generator)
+	if err != nil {
+		return err
+	}
+
+	if !returned.IP.Equal(leecher1.IP) {
+		return errors.New("non-compact announce did not return the IPv6 address of the other known leecher")
+	}
+
+	return nil
+}
+
+// ipv4IPv6SwarmMixingHTTPAnnounce reports whether the tracker returns IPv6
+// peers to IPv4 peers of the same swarm.
+func ipv4IPv6SwarmMixingHTTPAnnounce(announceURI string, cfg Config, compact bool) (string, error) {
+	g := cfg.Generator
+	if poke.Debug {
+		log.Println("Running ipv4IPv6SwarmMixingHTTPAnnounce")
+	}
+	c, err := cfg.newHTTPClient(announceURI)
+	if err != nil {
+		return "", poke.WrapError("unable to create client", err)
+	}
+	c.OverrideCompact(compact)
+
+	leecher1 := g.Peer()
+	leecher2 := g.Peer6()
+	leecher3 := g.Peer()
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     leecher1,
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	_, err = announce(c, req)
+	if err != nil {
+		return "", err
+	}
+
+	req.Peer = leecher2
+
+	_, err = announce(c, req)
+	if err != nil {
+		return "", err
+	}
+
+	req.Peer = leecher3
+
+	resp, err := announce(c, req)
+	if err != nil {
+		return "", err
+	}
+
+	var foundIPv4, foundIPv6 bool
+	for _, p := range resp.Peers {
+		switch {
+		case p.IsEqual(leecher1):
+			foundIPv4 = true
+		case p.IsEqual(leecher2):
+			foundIPv6 = true
+		}
+	}
+
+	if !foundIPv4 {
+		return "", errors.New("announce did not return the other known IPv4 leecher")
+	}
+
+	if foundIPv6 {
+		return SwarmsMixed, nil
+	}
+	return SwarmsSeparated, nil
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// fullParametersAnnounce checks that the tracker accepts announces carrying
+// all optional parameters real clients send.
+func fullParametersAnnounce(c poke.Announcer, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running fullParametersAnnounce")
+	}
+	req := poke.AnnounceRequest{
+		InfoHash:      g.InfoHash(),
+		Peer:          newPeer(c, g),
+		Event:         poke.EventStarted,
+		Numwant:       50,
+		Left:          100,
+		Key:           g.Key(),
+		NoPeerID:      true,
+		SupportCrypto: true,
+		Corrupt:       16384,
+		Redundant:     32768,
+		LocalIP:       net.IPv4(192, 168, 1, 2),
+	}
+
+	_, err := announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	req.Event = poke.EventNone
+	req.RequireCrypto = true
+	req.Left = 50
+
+	_, err = announce(c, req)
+	return err
+}
+
+// trackerHonoursNoPeerIDHTTPAnnounce reports whether the tracker leaves out
+// peer IDs from non-compact announce responses if the client asks for it.
+func trackerHonoursNoPeerIDHTTPAnnounce(announceURI string, cfg Config) (bool, error) {
+	g := cfg.Generator
+	if poke.Debug {
+		log.Println("Running trackerHonoursNoPeerIDHTTPAnnounce")
+	}
+	c, err := cfg.newHTTPClient(announceURI)
+	if err != nil {
+		return false, poke.WrapError("unable to create client", err)
+	}
+	c.OverrideCompact(false)
+
+	leecher1 := g.Peer()
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     leecher1,
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	_, err = announce(c, req)
+	if err != nil {
+		return false, err
+	}
+
+	req.Peer = g.Peer()
+	req.NoPeerID = true
+
+	resp, err := announce(c, req)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range resp.Peers {
+		if !p.IsEqual(leecher1) {
+			continue
+		}
+		switch p.ID {
+		case "":
+			return true, nil
+		case leecher1.ID:
+			return false, nil
+		}
+		return false, errors.New("announce returned the other known leecher with a different peer ID")
+	}
+
+	return false, errors.New("announce did not return the other known leecher")
+}
+
+// trackerSupportsKeyAnnounce reports whether the tracker identifies peers by
+// their key: a peer that changes its IP address must keep its place in the
+// swarm, while announces for its peer ID with a different key must not
+// change it.
+func trackerSupportsKeyAnnounce(c poke.Announcer, g *poke.Generator) (bool, error) {
+	if poke.Debug {
+		log.Println("Running trackerSupportsKeyAnnounce")
+	}
+	leecher := newPeer(c, g)
+	key := g.Key()
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     leecher,
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+		Key:      key,
+	}
+
+	_, err := announce(c, req)
+	if err != nil {
+		return false, err
+	}
+
+	// Take the IP addresses of otherwise unused peers, the Generator makes
+	// sure they are distinct.
+	moved := leecher
+	moved.IP = newPeer(c, g).IP
+	req.Peer = moved
+	req.Event = poke.EventNone
+
+	rejected, err := announceRejected(c, req)
+	if err != nil || rejected {
+		return false, err
+	}
+
+	forged := leecher
+	forged.IP = newPeer(c, g).IP
+	req.Peer = forged
+	req.Key = key + 1
+
+	_, err = announceRejected(c, req)
+	if err != nil {
+		return false, err
+	}
+
+	req = poke.AnnounceRequest{
+		InfoHash: req.InfoHash,
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	resp, err := announce(c, req)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range resp.Peers {
+		if p.IsEqual(leecher) {
+			return p.IP.Equal(moved.IP), nil
+		}
+	}
+
+	return false, errors.New("announce did not return the other known leecher")
+}
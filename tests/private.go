@@ -0,0 +1,324 @@
+package tests
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+)
+
+// PrivateTracker describes a private tracker, which only serves peers with a
+// known passkey and only for registered torrents.
+type PrivateTracker struct {
+	// Passkeys are valid passkeys. The announce URL of the tracker must
+	// contain the first one, as a path segment or a query parameter value.
+	// The other passkeys are substituted for it.
+	Passkeys []string
+
+	// InvalidPasskeys are passkeys the tracker must reject.
+	// If empty, a random passkey is used.
+	InvalidPasskeys []string
+
+	// InfoHashes are the infohashes of torrents registered with the
+	// tracker. Infohashes generated by the tests are assumed to be
+	// unregistered.
+	InfoHashes []poke.InfoHash
+}
+
+// withPasskey returns the announce URL with the path segments and query
+// parameter values equal to passkey replaced by replacement.
+func withPasskey(announceURI, passkey, replacement string) (string, error) {
+	u, err := url.Parse(announceURI)
+	if err != nil {
+		return "", poke.WrapError("invalid announce URL", err)
+	}
+
+	found := false
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if s == passkey {
+			segments[i] = replacement
+			found = true
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+
+	q := u.Query()
+	inQuery := false
+	for _, vs := range q {
+		for i, v := range vs {
+			if v == passkey {
+				vs[i] = replacement
+				inQuery = true
+			}
+		}
+	}
+	// Keep the query as is, unless it carries the passkey.
+	if inQuery {
+		u.RawQuery = q.Encode()
+	}
+
+	if !found && !inQuery {
+		return "", errors.New("announce URL does not contain the first passkey")
+	}
+	return u.String(), nil
+}
+
+// privateTracker returns the PrivateTracker of the Config, or a notRunError
+// if there is none.
+func privateTracker(cfg Config) (*PrivateTracker, error) {
+	switch {
+	case cfg.Private == nil:
+		return nil, notRunError("tracker is not configured as private")
+	case len(cfg.Private.Passkeys) == 0:
+		return nil, notRunError("no passkeys configured")
+	}
+	return cfg.Private, nil
+}
+
+// registeredInfoHashes returns the registered infohashes of the private
+// tracker, or a notRunError if there are none.
+func registeredInfoHashes(cfg Config) ([]poke.InfoHash, error) {
+	p, err := privateTracker(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.InfoHashes) == 0 {
+		return nil, notRunError("no registered infohashes configured")
+	}
+	return p.InfoHashes, nil
+}
+
+// newPrivateHTTPClient creates a client that announces with the given passkey
+// instead of the first one.
+func newPrivateHTTPClient(announceURI string, cfg Config, passkey string) (*http.Client, error) {
+	u, err := withPasskey(announceURI, cfg.Private.Passkeys[0], passkey)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := cfg.newHTTPClient(u)
+	if err != nil {
+		return nil, poke.WrapError("unable to create client", err)
+	}
+	return c, nil
+}
+
+// expectErrorResponse performs an announce and checks that the tracker
+// rejects it with an error response.
+func expectErrorResponse(c poke.Announcer, req poke.AnnounceRequest) error {
+	resp, err := c.Announce(req)
+	if err != nil {
+		return poke.WrapError("unable to perform announce", err)
+	}
+
+	switch resp.(type) {
+	case poke.ErrorResponse:
+		return nil
+	case poke.WarningResponse:
+		return errors.New("tracker returned a warning instead of an error")
+	}
+	return errors.New("tracker accepted the announce")
+}
+
+// privateValidPasskeyAnnounce checks that the tracker accepts announces with
+// every valid passkey for every registered torrent.
+// Passkeys are identified by their index in errors, to keep them out of
+// reports.
+func privateValidPasskeyAnnounce(announceURI string, cfg Config) error {
+	if poke.Debug {
+		log.Println("Running privateValidPasskeyAnnounce")
+	}
+	infoHashes, err := registeredInfoHashes(cfg)
+	if err != nil {
+		return err
+	}
+	g := cfg.Generator
+
+	for i, passkey := range cfg.Private.Passkeys {
+		c, err := newPrivateHTTPClient(announceURI, cfg, passkey)
+		if err != nil {
+			return err
+		}
+
+		for _, ih := range infoHashes {
+			req := poke.AnnounceRequest{
+				InfoHash: ih,
+				Peer:     g.Peer(),
+				Event:    poke.EventStarted,
+				Numwant:  50,
+				Left:     100,
+			}
+
+			_, err = announce(c, req)
+			if err != nil {
+				return poke.WrapError(fmt.Sprintf("passkey %d, infohash %x", i, []byte(ih)), err)
+			}
+
+			req.Event = poke.EventStopped
+			_, err = announce(c, req)
+			if err != nil {
+				return poke.WrapError(fmt.Sprintf("passkey %d, infohash %x", i, []byte(ih)), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// privateUnknownPasskeyAnnounce checks that the tracker rejects announces
+// with unknown passkeys.
+func privateUnknownPasskeyAnnounce(announceURI string, cfg Config) error {
+	if poke.Debug {
+		log.Println("Running privateUnknownPasskeyAnnounce")
+	}
+	infoHashes, err := registeredInfoHashes(cfg)
+	if err != nil {
+		return err
+	}
+	g := cfg.Generator
+
+	invalid := cfg.Private.InvalidPasskeys
+	if len(invalid) == 0 {
+		// Use the length of the valid passkey, in case the tracker checks
+		// it before looking the passkey up.
+		random := hex.EncodeToString(g.InfoHash())
+		if n := len(cfg.Private.Passkeys[0]); n < len(random) {
+			random = random[:n]
+		}
+		invalid = []string{random}
+	}
+
+	for i, passkey := range invalid {
+		c, err := newPrivateHTTPClient(announceURI, cfg, passkey)
+		if err != nil {
+			return err
+		}
+
+		err = expectErrorResponse(c, poke.AnnounceRequest{
+			InfoHash: infoHashes[0],
+			Peer:     g.Peer(),
+			Event:    poke.EventStarted,
+			Numwant:  50,
+			Left:     100,
+		})
+		if err != nil {
+			return poke.WrapError(fmt.Sprintf("invalid passkey %d", i), err)
+		}
+	}
+
+	return nil
+}
+
+// privateUnregisteredInfohashAnnounce checks that the tracker rejects
+// announces for torrents that are not registered.
+func privateUnregisteredInfohashAnnounce(announceURI string, cfg Config) error {
+	if poke.Debug {
+		log.Println("Running privateUnregisteredInfohashAnnounce")
+	}
+	_, err := privateTracker(cfg)
+	if err != nil {
+		return err
+	}
+	g := cfg.Generator
+
+	c, err := newPrivateHTTPClient(announceURI, cfg, cfg.Private.Passkeys[0])
+	if err != nil {
+		return err
+	}
+
+	return expectErrorResponse(c, poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     g.Peer(),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	})
+}
+
+// privateScrapeAccounting checks that scrapes of a registered torrent reflect
+// a peer starting, completing and stopping, consistently with the counts
+// returned in the announce responses.
+// Other peers announcing the torrent at the same time make the test fail.
+func privateScrapeAccounting(announceURI string, cfg Config) error {
+	if poke.Debug {
+		log.Println("Running privateScrapeAccounting")
+	}
+	infoHashes, err := registeredInfoHashes(cfg)
+	if err != nil {
+		return err
+	}
+	infoHash := infoHashes[0]
+
+	c, err := newPrivateHTTPClient(announceURI, cfg, cfg.Private.Passkeys[0])
+	if err != nil {
+		return err
+	}
+
+	resp, err := scrape(c, infoHash)
+	if err != nil {
+		return err
+	}
+	before, ok := findScrape(resp, infoHash)
+	if !ok {
+		return errors.New("scrape did not return the registered infohash")
+	}
+
+	req := poke.AnnounceRequest{
+		InfoHash: infoHash,
+		Peer:     cfg.Generator.Peer(),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	steps := []struct {
+		name                        string
+		event                       poke.Event
+		uploaded, downloaded, left  int
+		complete, incomplete, times int
+	}{
+		{"started", poke.EventStarted, 0, 0, 100, 0, 1, 0},
+		{"completed", poke.EventCompleted, 50, 100, 0, 1, 0, 1},
+		{"stopped", poke.EventStopped, 80, 100, 0, 0, 0, 1},
+	}
+
+	for _, step := range steps {
+		req.Event = step.event
+		req.Uploaded = step.uploaded
+		req.Downloaded = step.downloaded
+		req.Left = step.left
+
+		aResp, err := announce(c, req)
+		if err != nil {
+			return poke.WrapError(step.name, err)
+		}
+
+		resp, err := scrape(c, infoHash)
+		if err != nil {
+			return poke.WrapError(step.name, err)
+		}
+
+		err = checkScrape(resp, infoHash,
+			before.Complete+step.complete,
+			before.Incomplete+step.incomplete,
+			before.Downloaded+step.times)
+		if err != nil {
+			return poke.WrapError("after "+step.name, err)
+		}
+
+		f, _ := findScrape(resp, infoHash)
+		if aResp.Complete != f.Complete || aResp.Incomplete != f.Incomplete {
+			return fmt.Errorf("after %s: announce returned complete=%d, incomplete=%d, scrape returned complete=%d, incomplete=%d",
+				step.name, aResp.Complete, aResp.Incomplete, f.Complete, f.Incomplete)
+		}
+	}
+
+	return nil
+}
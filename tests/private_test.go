@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/tracker"
+)
+
+func TestWithPasskey(t *testing.T) {
+	table := []struct {
+		announce string
+		expected string
+	}{
+		{"http://tracker.org/abc/announce", "http://tracker.org/xyz/announce"},
+		{"http://tracker.org/announce?passkey=abc", "http://tracker.org/announce?passkey=xyz"},
+		{"http://tracker.org/announce.php?passkey=abc&x=1", "http://tracker.org/announce.php?passkey=xyz&x=1"},
+		{"http://tracker.org/abcd/announce", ""},
+		{"http://abc/announce", ""},
+	}
+
+	for _, tt := range table {
+		u, err := withPasskey(tt.announce, "abc", "xyz")
+		if tt.expected == "" {
+			assert.NotNil(t, err, tt.announce)
+			continue
+		}
+		assert.Nil(t, err, tt.announce)
+		assert.Equal(t, tt.expected, u)
+	}
+}
+
+func privateResults(t *testing.T, trackerCfg tracker.Config, announcePath string, private PrivateTracker) map[string]error {
+	srv := httptest.NewServer(tracker.New(trackerCfg))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.Private = &private
+	cfg.Tags = []Tag{TagPrivate}
+	res, err := TestHTTPTracker(srv.URL+announcePath, cfg)
+	require.Nil(t, err)
+
+	errs := make(map[string]error)
+	for _, test := range res.Tests {
+		if test.Run {
+			errs[test.Name] = test.Result.Err
+		}
+	}
+	return errs
+}
+
+func TestPrivateTracker(t *testing.T) {
+	registered := []poke.InfoHash{
+		poke.InfoHash("aaaaaaaaaaaaaaaaaaaa"),
+		poke.InfoHash("bbbbbbbbbbbbbbbbbbbb"),
+	}
+	cfg := tracker.DefaultConfig()
+	cfg.Passkeys = []string{"0123456789abcdef", "fedcba9876543210"}
+	cfg.InfoHashes = registered
+
+	private := PrivateTracker{
+		Passkeys:        cfg.Passkeys,
+		InvalidPasskeys: []string{"0000000000000000", ""},
+		InfoHashes:      registered,
+	}
+	passing := map[string]error{
+		"privateValidPasskeyAnnounce":         nil,
+		"privateUnknownPasskeyAnnounce":       nil,
+		"privateUnregisteredInfohashAnnounce": nil,
+		"privateScrapeAccounting":             nil,
+	}
+
+	for _, path := range []string{"/0123456789abcdef/announce", "/announce?passkey=0123456789abcdef"} {
+		assert.Equal(t, passing, privateResults(t, cfg, path, private), path)
+	}
+
+	private.InvalidPasskeys = nil
+	assert.Equal(t, passing, privateResults(t, cfg, "/0123456789abcdef/announce", private))
+
+	// A public tracker accepts everything.
+	public := tracker.DefaultConfig()
+	errs := privateResults(t, public, "/0123456789abcdef/announce", private)
+	assert.Nil(t, errs["privateValidPasskeyAnnounce"])
+	assert.NotNil(t, errs["privateUnknownPasskeyAnnounce"])
+	assert.NotNil(t, errs["privateUnregisteredInfohashAnnounce"])
+	assert.Nil(t, errs["privateScrapeAccounting"])
+
+	// Without registered infohashes, only the unregistered infohash test
+	// can be run.
+	errs = privateResults(t, cfg, "/0123456789abcdef/announce", PrivateTracker{Passkeys: cfg.Passkeys})
+	assert.Equal(t, map[string]error{"privateUnregisteredInfohashAnnounce": nil}, errs)
+
+	errs = privateResults(t, cfg, "/announce", private)
+	assert.NotNil(t, errs["privateUnregisteredInfohashAnnounce"])
+}
@@ -0,0 +1,645 @@
+package tests
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+	"github.com/mrd0ll4r/poke/udp"
+)
+
+// Protocol is a protocol tests can be run on.
+type Protocol string
+
+// Protocols tests can be run on.
+const (
+	ProtocolHTTP     Protocol = "http"
+	ProtocolHTTPIPv6 Protocol = "http-ipv6"
+	ProtocolUDP      Protocol = "udp"
+)
+
+// Tag categorizes a test.
+type Tag string
+
+// Tags for tests.
+const (
+	// TagConformance marks tests that check behaviour every tracker should
+	// implement.
+	TagConformance Tag = "conformance"
+
+	// TagNegative marks tests that check how invalid requests are handled.
+	TagNegative Tag = "negative"
+
+	// TagCapability marks tests that detect optional features of a tracker.
+	TagCapability Tag = "capability"
+
+	// TagTransport marks tests that check the HTTP responses of a tracker at
+	// the transport level.
+	TagTransport Tag = "transport"
+
+	// TagTLS marks tests that check the TLS setup of HTTPS trackers.
+	TagTLS Tag = "tls"
+
+	// TagPrivate marks tests that check the access control of private
+	// trackers.
+	TagPrivate Tag = "private"
+)
+
+// Capability is a feature of a tracker, as detected by a test.
+type Capability string
+
+// Capabilities detected by tests.
+const (
+	CapabilityAnnounce                    Capability = "announce"
+	CapabilityCompact                     Capability = "compact"
+	CapabilityNonCompact                  Capability = "nonCompact"
+	CapabilityAnnouncingPeerNotInPeerList Capability = "announcingPeerNotInPeerList"
+	CapabilityIPSpoofing                  Capability = "ipSpoofing"
+	CapabilityOptimizedSeederResponse     Capability = "optimizedSeederResponse"
+	CapabilityScrape                      Capability = "scrape"
+	CapabilityIPv6                        Capability = "ipv6"
+	CapabilityRejectsForgedConnectionIDs  Capability = "rejectsForgedConnectionIDs"
+	CapabilityConnectionIDReuse           Capability = "connectionIDReuse"
+	CapabilityConnectionIDBoundToAddress  Capability = "connectionIDBoundToAddress"
+	CapabilityConnectionIDExpiry          Capability = "connectionIDExpiry"
+	CapabilityURLData                     Capability = "urlData"
+	CapabilityNoPeerID                    Capability = "noPeerID"
+	CapabilityKey                         Capability = "key"
+)
+
+var capabilityDescriptions = map[Capability]string{
+	CapabilityAnnounce:                    "announces",
+	CapabilityCompact:                     "compact announces",
+	CapabilityNonCompact:                  "non-compact announces",
+	CapabilityAnnouncingPeerNotInPeerList: "leaving the announcing peer out of the peer list",
+	CapabilityIPSpoofing:                  "IP spoofing",
+	CapabilityOptimizedSeederResponse:     "optimized seeder responses",
+	CapabilityScrape:                      "scrape",
+	CapabilityIPv6:                        "IPv6",
+	CapabilityRejectsForgedConnectionIDs:  "rejecting forged connection IDs",
+	CapabilityConnectionIDReuse:           "reusing connection IDs",
+	CapabilityConnectionIDBoundToAddress:  "binding connection IDs to addresses",
+	CapabilityConnectionIDExpiry:          "expiring connection IDs",
+	CapabilityURLData:                     "URL data",
+	CapabilityNoPeerID:                    "no_peer_id",
+	CapabilityKey:                         "identifying peers by key",
+}
+
+// Definition describes a test.
+type Definition struct {
+	Name      string
+	Protocols []Protocol
+	Tags      []Tag
+
+	// Depends are the capabilities the test requires. If the tracker lacks
+	// one of them, the test is not run.
+	Depends []Capability
+
+	// Uses are the capabilities the test adapts to, without requiring them.
+	Uses []Capability
+
+	// Produces are the capabilities the test detects.
+	// A capability is detected if the test succeeds and, if it returns a
+	// bool, returns true. Capabilities produced by multiple tests are
+	// detected if any of them detects it.
+	Produces []Capability
+
+	run func(*suite) (interface{}, error)
+}
+
+// HasProtocol reports whether the test can be run on the given protocol.
+func (d Definition) HasProtocol(p Protocol) bool {
+	for _, dp := range d.Protocols {
+		if dp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTag reports whether the test is tagged with the given tag.
+func (d Definition) HasTag(t Tag) bool {
+	for _, dt := range d.Tags {
+		if dt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// notRunError is returned by tests that can not be run.
+// Its message is used as the reason the test was not run.
+type notRunError string
+
+func (e notRunError) Error() string {
+	return string(e)
+}
+
+var allProtocols = []Protocol{ProtocolHTTP, ProtocolHTTPIPv6, ProtocolUDP}
+
+var httpProtocols = []Protocol{ProtocolHTTP, ProtocolHTTPIPv6}
+
+// registry contains all tests, in the order they are run.
+// Tests producing a capability must come before the tests depending on or
+// using it.
+var registry = []Definition{
+	{
+		Name:      "trackerSupportsCompactAnnounce",
+		Protocols: httpProtocols,
+		Tags:      []Tag{TagCapability},
+		Produces:  []Capability{CapabilityCompact, CapabilityAnnounce},
+		run: func(s *suite) (interface{}, error) {
+			return trackerSupportsCompactHTTPAnnounce(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "trackerSupportsNonCompactAnnounce",
+		Protocols: httpProtocols,
+		Tags:      []Tag{TagCapability},
+		Produces:  []Capability{CapabilityNonCompact, CapabilityAnnounce},
+		run: func(s *suite) (interface{}, error) {
+			return trackerSupportsNonCompactHTTPAnnounce(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "trackerSupportsIPv6Announce",
+		Protocols: []Protocol{ProtocolHTTPIPv6},
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Uses:      []Capability{CapabilityCompact},
+		Produces:  []Capability{CapabilityIPv6},
+		run: func(s *suite) (interface{}, error) {
+			return trackerSupportsIPv6HTTPAnnounce(s.tracker, s.cfg, s.caps[CapabilityCompact])
+		},
+	},
+	{
+		Name:      "ipv6NonCompactAnnounce",
+		Protocols: []Protocol{ProtocolHTTPIPv6},
+		Tags:      []Tag{TagConformance},
+		Depends:   []Capability{CapabilityIPv6, CapabilityNonCompact},
+		run: func(s *suite) (interface{}, error) {
+			return nil, ipv6NonCompactHTTPAnnounce(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "ipv4IPv6SwarmMixing",
+		Protocols: []Protocol{ProtocolHTTPIPv6},
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityIPv6},
+		Uses:      []Capability{CapabilityCompact},
+		run: func(s *suite) (interface{}, error) {
+			return ipv4IPv6SwarmMixingHTTPAnnounce(s.tracker, s.cfg, s.caps[CapabilityCompact])
+		},
+	},
+	{
+		Name:      "trackerSupportsAnnouncingPeerNotInPeerListAnnounce",
+		Protocols: allProtocols,
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Produces:  []Capability{CapabilityAnnouncingPeerNotInPeerList},
+		run: func(s *suite) (interface{}, error) {
+			c, err := s.announcer()
+			if err != nil {
+				return nil, err
+			}
+			return trackerSupportsAnnouncingPeerNotInPeerListAnnounce(c, s.cfg.Generator)
+		},
+	},
+	{
+		Name:      "trackerSupportsIPSpoofingAnnounce",
+		Protocols: allProtocols,
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Uses:      []Capability{CapabilityAnnouncingPeerNotInPeerList},
+		Produces:  []Capability{CapabilityIPSpoofing},
+		run: func(s *suite) (interface{}, error) {
+			c, err := s.announcer()
+			if err != nil {
+				return nil, err
+			}
+			return trackerSupportsIPSpoofingAnnounce(c, s.cfg.Generator, s.caps[CapabilityAnnouncingPeerNotInPeerList])
+		},
+	},
+	{
+		Name:      "trackerSupportsOptimizedSeederResponseAnnounce",
+		Protocols: allProtocols,
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Produces:  []Capability{CapabilityOptimizedSeederResponse},
+		run: func(s *suite) (interface{}, error) {
+			c, err := s.announcer()
+			if err != nil {
+				return nil, err
+			}
+			return trackerSupportsOptimizedSeederAnnounce(c, s.cfg.Generator)
+		},
+	},
+	{
+		Name:      "basicAnnounce",
+		Protocols: allProtocols,
+		Tags:      []Tag{TagConformance},
+		Depends:   []Capability{CapabilityAnnounce},
+		Uses:      []Capability{CapabilityAnnouncingPeerNotInPeerList},
+		run: func(s *suite) (interface{}, error) {
+			c, err := s.announcer()
+			if err != nil {
+				return nil, err
+			}
+			return nil, basicAnnounce(c, s.cfg.Generator, s.caps[CapabilityAnnouncingPeerNotInPeerList])
+		},
+	},
+	{
+		Name:      "fullParametersAnnounce",
+		Protocols: httpProtocols,
+		Tags:      []Tag{TagConformance},
+		Depends:   []Capability{CapabilityAnnounce},
+		run: func(s *suite) (interface{}, error) {
+			c, err := s.announcer()
+			if err != nil {
+				return nil, err
+			}
+			return nil, fullParametersAnnounce(c, s.cfg.Generator)
+		},
+	},
+	{
+		Name:      "trackerHonoursNoPeerIDAnnounce",
+		Protocols: httpProtocols,
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityNonCompact},
+		Produces:  []Capability{CapabilityNoPeerID},
+		run: func(s *suite) (interface{}, error) {
+			return trackerHonoursNoPeerIDHTTPAnnounce(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "trackerSupportsKeyAnnounce",
+		Protocols: allProtocols,
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce, CapabilityIPSpoofing},
+		Produces:  []Capability{CapabilityKey},
+		run: func(s *suite) (interface{}, error) {
+			c, err := s.announcer()
+			if err != nil {
+				return nil, err
+			}
+			return trackerSupportsKeyAnnounce(c, s.cfg.Generator)
+		},
+	},
+	{
+		Name:      "trackerSupportsScrape",
+		Protocols: allProtocols,
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Produces:  []Capability{CapabilityScrape},
+		run: func(s *suite) (interface{}, error) {
+			c, sc, err := s.scraper()
+			if err != nil {
+				return nil, err
+			}
+			err = trackerSupportsScrape(c, sc, s.cfg.Generator)
+			return err == nil, err
+		},
+	},
+	scrapeTest("scrapeUnknownInfohash", scrapeUnknownInfohash),
+	scrapeTest("scrapeDownloadedIncrements", scrapeDownloadedIncrements),
+	scrapeTest("scrapeMultipleInfohashes", scrapeMultipleInfohashes),
+	scrapeTest("scrapeAfterStopped", scrapeAfterStopped),
+	negativeTest("invalidShortInfohashAnnounce", invalidShortInfohashAnnounce),
+	negativeTest("invalidLongInfohashAnnounce", invalidLongInfohashAnnounce),
+	negativeTest("invalidShortPeerIDAnnounce", invalidShortPeerIDAnnounce),
+	negativeTest("invalidLongPeerIDAnnounce", invalidLongPeerIDAnnounce),
+	negativeTest("invalidNegativeUploadedAnnounce", invalidNegativeUploadedAnnounce),
+	negativeTest("invalidNegativeDownloadedAnnounce", invalidNegativeDownloadedAnnounce),
+	negativeTest("invalidNegativeLeftAnnounce", invalidNegativeLeftAnnounce),
+	negativeTest("invalidEventAnnounce", invalidEventAnnounce),
+	{
+		Name:      "udpURLDataAnnounce",
+		Protocols: []Protocol{ProtocolUDP},
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Produces:  []Capability{CapabilityURLData},
+		run: func(s *suite) (interface{}, error) {
+			return udpURLDataAnnounce(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "udpRejectsForgedConnectionIDAnnounce",
+		Protocols: []Protocol{ProtocolUDP},
+		Tags:      []Tag{TagCapability, TagNegative},
+		Depends:   []Capability{CapabilityAnnounce},
+		Produces:  []Capability{CapabilityRejectsForgedConnectionIDs},
+		run: func(s *suite) (interface{}, error) {
+			return udpRejectsForgedConnectionIDAnnounce(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "udpConnectionIDReuseAnnounce",
+		Protocols: []Protocol{ProtocolUDP},
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Produces:  []Capability{CapabilityConnectionIDReuse},
+		run: func(s *suite) (interface{}, error) {
+			return udpConnectionIDReuseAnnounce(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "udpConnectionIDBoundToAddressAnnounce",
+		Protocols: []Protocol{ProtocolUDP},
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Produces:  []Capability{CapabilityConnectionIDBoundToAddress},
+		run: func(s *suite) (interface{}, error) {
+			return udpConnectionIDBoundToAddressAnnounce(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "udpConnectionIDExpiryAnnounce",
+		Protocols: []Protocol{ProtocolUDP},
+		Tags:      []Tag{TagCapability},
+		Depends:   []Capability{CapabilityAnnounce},
+		Produces:  []Capability{CapabilityConnectionIDExpiry},
+		run: func(s *suite) (interface{}, error) {
+			return udpConnectionIDExpiryAnnounce(s.tracker, s.cfg)
+		},
+	},
+	transportTest("httpAnnounceTransport", CapabilityAnnounce, httpAnnounceTransport),
+	transportTest("httpGzipAnnounceTransport", CapabilityAnnounce, httpGzipAnnounceTransport),
+	transportTest("httpFailureTransport", CapabilityAnnounce, httpFailureTransport),
+	transportTest("httpScrapeTransport", CapabilityScrape, httpScrapeTransport),
+	{
+		Name:      "tlsHandshake",
+		Protocols: []Protocol{ProtocolHTTP},
+		Tags:      []Tag{TagConformance, TagTLS},
+		run: func(s *suite) (interface{}, error) {
+			return tlsHandshake(s.tracker, s.cfg)
+		},
+	},
+	{
+		Name:      "tlsPlainHTTPRedirect",
+		Protocols: []Protocol{ProtocolHTTP},
+		Tags:      []Tag{TagTLS},
+		run: func(s *suite) (interface{}, error) {
+			return tlsPlainHTTPRedirect(s.tracker, s.cfg)
+		},
+	},
+	privateTest("privateValidPasskeyAnnounce", TagConformance, privateValidPasskeyAnnounce),
+	privateTest("privateUnknownPasskeyAnnounce", TagNegative, privateUnknownPasskeyAnnounce),
+	privateTest("privateUnregisteredInfohashAnnounce", TagNegative, privateUnregisteredInfohashAnnounce),
+	privateTest("privateScrapeAccounting", TagConformance, privateScrapeAccounting),
+	udpNegativeTest("udpTruncatedConnect", udpTruncatedConnect),
+	udpNegativeTest("udpTruncatedAnnounce", udpTruncatedAnnounce),
+	udpNegativeTest("udpWrongConnectionIDAnnounce", udpWrongConnectionIDAnnounce),
+	udpNegativeTest("udpOversizeAnnounce", udpOversizeAnnounce),
+	udpNegativeTest("udpInvalidActionRequest", udpInvalidActionRequest),
+	udpNegativeTest("udpInvalidEventAnnounce", udpInvalidEventAnnounce),
+	udpNegativeTest("udpNumwantOverflowAnnounce", udpNumwantOverflowAnnounce),
+}
+
+// scrapeTest defines a conformance test of the scrape implementation.
+func scrapeTest(name string, f func(poke.Announcer, poke.Scraper, *poke.Generator) error) Definition {
+	return Definition{
+		Name:      name,
+		Protocols: allProtocols,
+		Tags:      []Tag{TagConformance},
+		Depends:   []Capability{CapabilityScrape},
+		run: func(s *suite) (interface{}, error) {
+			c, sc, err := s.scraper()
+			if err != nil {
+				return nil, err
+			}
+			return nil, f(c, sc, s.cfg.Generator)
+		},
+	}
+}
+
+// negativeTest defines a test that checks that the tracker rejects an invalid
+// HTTP announce.
+func negativeTest(name string, f func(poke.Announcer, *poke.Generator) error) Definition {
+	return Definition{
+		Name:      name,
+		Protocols: httpProtocols,
+		Tags:      []Tag{TagNegative},
+		Depends:   []Capability{CapabilityAnnounce},
+		run: func(s *suite) (interface{}, error) {
+			c, err := s.announcer()
+			if err != nil {
+				return nil, err
+			}
+			return nil, f(c, s.cfg.Generator)
+		},
+	}
+}
+
+// transportTest defines a test that checks an HTTP response of the tracker
+// against the expectations of the Config.
+func transportTest(name string, depends Capability, f func(*http.Client, Config) (HTTPResponseInfo, error)) Definition {
+	return Definition{
+		Name:      name,
+		Protocols: []Protocol{ProtocolHTTP},
+		Tags:      []Tag{TagConformance, TagTransport},
+		Depends:   []Capability{depends},
+		Uses:      []Capability{CapabilityCompact},
+		run: func(s *suite) (interface{}, error) {
+			c, err := s.cfg.newHTTPClient(s.tracker)
+			if err != nil {
+				return nil, poke.WrapError("unable to create client", err)
+			}
+			c.OverrideCompact(s.caps[CapabilityCompact])
+			return f(c, s.cfg)
+		},
+	}
+}
+
+// privateTest defines a test of the access control of a private HTTP
+// tracker. It does not depend on any capabilities, because the tests
+// detecting them announce unregistered torrents.
+func privateTest(name string, tag Tag, f func(string, Config) error) Definition {
+	return Definition{
+		Name:      name,
+		Protocols: []Protocol{ProtocolHTTP},
+		Tags:      []Tag{tag, TagPrivate},
+		run: func(s *suite) (interface{}, error) {
+			return nil, f(s.tracker, s.cfg)
+		},
+	}
+}
+
+// udpNegativeTest defines a test that checks that the tracker rejects or
+// drops an invalid UDP packet.
+//
+// Every test uses a new client, because the transaction IDs of truncated
+// packets are unknown, so responses to them the client gave up waiting for
+// could be read as responses to later requests.
+func udpNegativeTest(name string, f func(*udp.Client, *poke.Generator) error) Definition {
+	return Definition{
+		Name:      name,
+		Protocols: []Protocol{ProtocolUDP},
+		Tags:      []Tag{TagNegative},
+		Depends:   []Capability{CapabilityAnnounce},
+		run: func(s *suite) (interface{}, error) {
+			if err := requireTimeout(s.cfg); err != nil {
+				return nil, err
+			}
+			c, err := s.cfg.newUDPClient(s.tracker)
+			if err != nil {
+				return nil, poke.WrapError("unable to create client", err)
+			}
+			defer c.Close()
+			return nil, f(c, s.cfg.Generator)
+		},
+	}
+}
+
+// Definitions returns the definitions of all tests, in the order they are
+// run.
+func Definitions() []Definition {
+	return append([]Definition(nil), registry...)
+}
+
+// selected returns the tests to run on the given protocol.
+// Tests producing capabilities the selected tests depend on or use are
+// selected as well.
+func selected(p Protocol, cfg Config) []Definition {
+	needed := make(map[Capability]bool)
+	include := make([]bool, len(registry))
+
+	// Producers come before their consumers, so one pass in reverse order
+	// finds all of them.
+	for i := len(registry) - 1; i >= 0; i-- {
+		d := registry[i]
+		if !d.HasProtocol(p) {
+			continue
+		}
+
+		include[i] = cfg.Selects(d)
+		for _, c := range d.Produces {
+			include[i] = include[i] || needed[c]
+		}
+		if !include[i] {
+			continue
+		}
+
+		for _, c := range d.Depends {
+			needed[c] = true
+		}
+		for _, c := range d.Uses {
+			needed[c] = true
+		}
+	}
+
+	var defs []Definition
+	for i, d := range registry {
+		if include[i] {
+			defs = append(defs, d)
+		}
+	}
+	return defs
+}
+
+// suite runs the tests for a tracker and keeps track of the capabilities
+// they detected.
+type suite struct {
+	protocol Protocol
+	tracker  string
+	cfg      Config
+	caps     map[Capability]bool
+
+	newAnnouncer func() (poke.Announcer, error)
+	client       poke.Announcer
+}
+
+func newSuite(p Protocol, tracker string, cfg Config, newAnnouncer func() (poke.Announcer, error)) *suite {
+	return &suite{
+		protocol:     p,
+		tracker:      tracker,
+		cfg:          cfg,
+		caps:         make(map[Capability]bool),
+		newAnnouncer: newAnnouncer,
+	}
+}
+
+// announcer returns the Announcer shared by the tests.
+// It is created when it is first needed, after the capabilities it depends
+// on were detected.
+func (s *suite) announcer() (poke.Announcer, error) {
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	c, err := s.newAnnouncer()
+	if err != nil {
+		return nil, poke.WrapError("unable to create client", err)
+	}
+	s.client = c
+	return c, nil
+}
+
+// scraper returns the Announcer shared by the tests and its Scraper.
+func (s *suite) scraper() (poke.Announcer, poke.Scraper, error) {
+	c, err := s.announcer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc, ok := c.(poke.Scraper)
+	if !ok {
+		return nil, nil, notRunError("client does not implement scrape")
+	}
+	return c, sc, nil
+}
+
+// run runs the selected tests and records them and the detected
+// capabilities in the result.
+func (s *suite) run(result *TrackerResult) {
+	for _, d := range selected(s.protocol, s.cfg) {
+		result.Tests = append(result.Tests, s.runTest(d))
+	}
+
+	result.SupportsAnnouncingPeerNotInPeerList = s.caps[CapabilityAnnouncingPeerNotInPeerList]
+	result.SupportsIPSpoofing = s.caps[CapabilityIPSpoofing]
+	result.SupportsOptimizedSeederResponse = s.caps[CapabilityOptimizedSeederResponse]
+	result.SupportsScrape = s.caps[CapabilityScrape]
+	result.SupportsIPv6 = s.caps[CapabilityIPv6]
+	result.IdentifiesPeersByKey = s.caps[CapabilityKey]
+}
+
+func (s *suite) runTest(d Definition) Test {
+	t := Test{
+		Name: d.Name,
+	}
+
+	for _, c := range d.Depends {
+		if !s.caps[c] {
+			t.NotRunReason = "tracker does not support " + capabilityDescriptions[c]
+			return t
+		}
+	}
+
+	start := time.Now()
+	res, err := d.run(s)
+	findings := s.cfg.findings.take()
+	if reason, ok := err.(notRunError); ok {
+		t.NotRunReason = string(reason)
+		return t
+	}
+	t.Duration = time.Since(start)
+	t.Run = true
+
+	if err == nil && s.cfg.Strict && len(findings) > 0 {
+		err = fmt.Errorf("response is not canonical bencode: %s (%d findings)", findings[0], len(findings))
+	}
+	t.Result.Result = res
+	t.Result.Err = err
+	t.Result.Findings = findings
+
+	detected := err == nil
+	if b, ok := res.(bool); ok {
+		detected = detected && b
+	}
+	for _, c := range d.Produces {
+		s.caps[c] = s.caps[c] || detected
+	}
+
+	return t
+}
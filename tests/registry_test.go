@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke/tracker"
+)
+
+func TestRegistry(t *testing.T) {
+	names := make(map[string]bool)
+	for _, p := range allProtocols {
+		produced := map[Capability]bool{}
+		if p == ProtocolUDP {
+			produced[CapabilityAnnounce] = true
+		}
+
+		for _, d := range registry {
+			if !d.HasProtocol(p) {
+				continue
+			}
+			names[d.Name] = true
+
+			for _, c := range append(d.Depends, d.Uses...) {
+				assert.True(t, produced[c], "%s: %s needs %s before it is produced", p, d.Name, c)
+			}
+			for _, c := range d.Produces {
+				produced[c] = true
+			}
+		}
+	}
+
+	assert.Equal(t, len(registry), len(names), "test names are not unique")
+	for c := range capabilityDescriptions {
+		assert.NotEmpty(t, capabilityDescriptions[c])
+	}
+}
+
+func testNames(ts []Test) []string {
+	var names []string
+	for _, t := range ts {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func TestSelectionRunsPrerequisites(t *testing.T) {
+	conn, err := tracker.New(tracker.DefaultConfig()).ListenUDP("127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	cfg := testConfig()
+	cfg.Run = regexp.MustCompile("^scrapeAfterStopped$")
+	res, err := TestUDPTracker(conn.LocalAddr().String(), cfg)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"trackerSupportsScrape", "scrapeAfterStopped"}, testNames(res.Tests))
+	checkTests(t, res.TrackerResult)
+	assert.True(t, res.SupportsScrape)
+	assert.False(t, res.SupportsIPSpoofing)
+}
+
+func TestSelectionTags(t *testing.T) {
+	srv := httptest.NewServer(tracker.New(tracker.DefaultConfig()))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.Tags = []Tag{TagCapability}
+	res, err := TestHTTPTracker(srv.URL+"/announce", cfg)
+	require.Nil(t, err)
+
+	var expected []string
+	for _, d := range registry {
+		if d.HasProtocol(ProtocolHTTP) && d.HasTag(TagCapability) {
+			expected = append(expected, d.Name)
+		}
+	}
+	assert.Equal(t, expected, testNames(res.Tests))
+	assert.Equal(t, capabilities{true, true, true, true}, detected(res.TrackerResult))
+}
+
+func TestDependentsNotRun(t *testing.T) {
+	cfg := tracker.DefaultConfig()
+	cfg.Scrape = false
+	srv := httptest.NewServer(tracker.New(cfg))
+	defer srv.Close()
+
+	res, err := TestHTTPTracker(srv.URL+"/announce", testConfig())
+	require.Nil(t, err)
+	for _, test := range res.Tests {
+		d := findDefinition(test.Name)
+		switch {
+		case d.HasTag(TagTLS), d.HasTag(TagPrivate):
+			// The tracker is neither served via HTTPS nor private.
+			assert.False(t, test.Run, test.Name)
+		case len(d.Depends) == 1 && d.Depends[0] == CapabilityScrape:
+			assert.False(t, test.Run, test.Name)
+			assert.Equal(t, "tracker does not support scrape", test.NotRunReason)
+		default:
+			assert.True(t, test.Run, test.Name)
+		}
+	}
+
+	srv2 := httptest.NewServer(http.NotFoundHandler())
+	defer srv2.Close()
+
+	res, err = TestHTTPTracker(srv2.URL+"/announce", testConfig())
+	require.Nil(t, err)
+	require.True(t, len(res.Tests) > 2)
+	for _, test := range res.Tests[2:] {
+		assert.False(t, test.Run, test.Name)
+		if d := findDefinition(test.Name); d.HasTag(TagTLS) || d.HasTag(TagPrivate) {
+			continue
+		}
+		assert.Contains(t, test.NotRunReason, "tracker does not support ", test.Name)
+	}
+}
+
+func findDefinition(name string) Definition {
+	for _, d := range registry {
+		if d.Name == name {
+			return d
+		}
+	}
+	return Definition{}
+}
@@ -0,0 +1,321 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// announce performs an announce and turns error and warning responses into
+// errors.
+func announce(c poke.Announcer, req poke.AnnounceRequest) (poke.AnnounceResponse, error) {
+	resp, err := c.Announce(req)
+	if err != nil {
+		return poke.AnnounceResponse{}, poke.WrapError("unable to perform announce", err)
+	}
+
+	switch resp := resp.(type) {
+	case poke.AnnounceResponse:
+		return resp, nil
+	case poke.ErrorResponse:
+		return poke.AnnounceResponse{}, errors.New("tracker returned error: " + string(resp))
+	case poke.WarningResponse:
+		return poke.AnnounceResponse{}, errors.New("tracker returned warning: " + string(resp))
+	}
+
+	return poke.AnnounceResponse{}, errors.New("unknown announce response")
+}
+
+// scrape performs a scrape and turns error responses into errors.
+func scrape(s poke.Scraper, infoHashes ...poke.InfoHash) (poke.ScrapeResponse, error) {
+	resp, err := s.Scrape(poke.ScrapeRequest{InfoHashes: infoHashes})
+	if err != nil {
+		return poke.ScrapeResponse{}, poke.WrapError("unable to perform scrape", err)
+	}
+
+	switch resp := resp.(type) {
+	case poke.ScrapeResponse:
+		return resp, nil
+	case poke.ErrorResponse:
+		return poke.ScrapeResponse{}, errors.New("tracker returned error: " + string(resp))
+	}
+
+	return poke.ScrapeResponse{}, errors.New("unknown scrape response")
+}
+
+// findScrape returns the scrape information for the given infohash, if
+// present.
+func findScrape(resp poke.ScrapeResponse, infoHash poke.InfoHash) (poke.Scrape, bool) {
+	for _, f := range resp.Files {
+		if bytes.Equal(f.InfoHash, infoHash) {
+			return f, true
+		}
+	}
+	return poke.Scrape{}, false
+}
+
+// checkScrape checks that the scrape information for the given infohash
+// matches the expected values.
+func checkScrape(resp poke.ScrapeResponse, infoHash poke.InfoHash, complete, incomplete, downloaded int) error {
+	f, ok := findScrape(resp, infoHash)
+	if !ok {
+		return errors.New("scrape did not return the scraped infohash")
+	}
+
+	if f.Complete != complete || f.Incomplete != incomplete || f.Downloaded != downloaded {
+		return fmt.Errorf("scrape returned complete=%d, incomplete=%d, downloaded=%d, expected complete=%d, incomplete=%d, downloaded=%d",
+			f.Complete, f.Incomplete, f.Downloaded, complete, incomplete, downloaded)
+	}
+
+	return nil
+}
+
+func trackerSupportsScrape(c poke.Announcer, s poke.Scraper, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running trackerSupportsScrape")
+	}
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	_, err := announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	req.Peer = newPeer(c, g)
+	req.Left = 0
+
+	_, err = announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := scrape(s, req.InfoHash)
+	if err != nil {
+		return err
+	}
+
+	f, ok := findScrape(resp, req.InfoHash)
+	if !ok {
+		return errors.New("scrape did not return the scraped infohash")
+	}
+
+	if f.Complete != 1 || f.Incomplete != 1 {
+		return fmt.Errorf("scrape after announcing one leecher and one seeder returned complete=%d, incomplete=%d", f.Complete, f.Incomplete)
+	}
+
+	return nil
+}
+
+func scrapeUnknownInfohash(c poke.Announcer, s poke.Scraper, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running scrapeUnknownInfohash")
+	}
+	infoHash := g.InfoHash()
+
+	resp, err := s.Scrape(poke.ScrapeRequest{InfoHashes: []poke.InfoHash{infoHash}})
+	if err != nil {
+		return poke.WrapError("unable to perform scrape", err)
+	}
+
+	switch resp := resp.(type) {
+	case poke.ScrapeResponse:
+		f, ok := findScrape(resp, infoHash)
+		if !ok {
+			return nil
+		}
+		if f.Complete != 0 || f.Incomplete != 0 || f.Downloaded != 0 {
+			return errors.New("scrape of unknown infohash returned non-zero values")
+		}
+	case poke.ErrorResponse:
+		// Rejecting unknown infohashes is fine.
+	}
+
+	return nil
+}
+
+func scrapeDownloadedIncrements(c poke.Announcer, s poke.Scraper, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running scrapeDownloadedIncrements")
+	}
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	_, err := announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := scrape(s, req.InfoHash)
+	if err != nil {
+		return err
+	}
+
+	err = checkScrape(resp, req.InfoHash, 0, 1, 0)
+	if err != nil {
+		return poke.WrapError("before completed", err)
+	}
+
+	req.Event = poke.EventCompleted
+	req.Downloaded = 100
+	req.Left = 0
+
+	_, err = announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	resp, err = scrape(s, req.InfoHash)
+	if err != nil {
+		return err
+	}
+
+	err = checkScrape(resp, req.InfoHash, 1, 0, 1)
+	if err != nil {
+		return poke.WrapError("after completed", err)
+	}
+
+	return nil
+}
+
+func scrapeMultipleInfohashes(c poke.Announcer, s poke.Scraper, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running scrapeMultipleInfohashes")
+	}
+	infoHash1 := g.InfoHash()
+	infoHash2 := g.InfoHash()
+	infoHash3 := g.InfoHash()
+
+	req := poke.AnnounceRequest{
+		InfoHash: infoHash1,
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	_, err := announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	req.InfoHash = infoHash2
+	req.Left = 0
+	for i := 0; i < 2; i++ {
+		req.Peer = newPeer(c, g)
+		_, err = announce(c, req)
+		if err != nil {
+			return err
+		}
+	}
+
+	req.InfoHash = infoHash3
+	for i := 0; i < 3; i++ {
+		req.Peer = newPeer(c, g)
+		_, err = announce(c, req)
+		if err != nil {
+			return err
+		}
+	}
+
+	order := []poke.InfoHash{infoHash2, infoHash3, infoHash1}
+	resp, err := scrape(s, order...)
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Files) != len(order) {
+		return fmt.Errorf("scrape of %d infohashes returned %d files", len(order), len(resp.Files))
+	}
+
+	for i, f := range resp.Files {
+		if !bytes.Equal(f.InfoHash, order[i]) {
+			return errors.New("scrape did not return files in request order")
+		}
+	}
+
+	err = checkScrape(resp, infoHash1, 0, 1, 0)
+	if err != nil {
+		return err
+	}
+	err = checkScrape(resp, infoHash2, 2, 0, 0)
+	if err != nil {
+		return err
+	}
+	return checkScrape(resp, infoHash3, 3, 0, 0)
+}
+
+func scrapeAfterStopped(c poke.Announcer, s poke.Scraper, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running scrapeAfterStopped")
+	}
+	leecher := newPeer(c, g)
+	seeder := newPeer(c, g)
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     leecher,
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	_, err := announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	req.Peer = seeder
+	req.Left = 0
+
+	_, err = announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	req.Event = poke.EventStopped
+
+	_, err = announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	req.Peer = leecher
+	req.Left = 100
+
+	_, err = announce(c, req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := scrape(s, req.InfoHash)
+	if err != nil {
+		return err
+	}
+
+	f, ok := findScrape(resp, req.InfoHash)
+	if !ok {
+		return nil
+	}
+
+	if f.Complete != 0 || f.Incomplete != 0 {
+		return fmt.Errorf("scrape after all peers stopped returned complete=%d, incomplete=%d", f.Complete, f.Incomplete)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+)
+
+// Results of the tlsPlainHTTPRedirect test.
+const (
+	PlainHTTPRedirects   = "redirects"
+	PlainHTTPServed      = "served"
+	PlainHTTPUnavailable = "unavailable"
+)
+
+// TLSInfo describes the TLS connection to an HTTPS tracker.
+type TLSInfo struct {
+	Version       string `json:"version"`
+	CipherSuite   string `json:"cipherSuite"`
+	ChainValid    bool   `json:"chainValid"`
+	ChainError    string `json:"chainError,omitempty"`
+	ExpiresInDays int    `json:"expiresInDays"`
+}
+
+func (i TLSInfo) String() string {
+	s := fmt.Sprintf("%s, %s, certificate expires in %d days", i.Version, i.CipherSuite, i.ExpiresInDays)
+	if !i.ChainValid {
+		s += ", invalid chain: " + i.ChainError
+	}
+	return s
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+func tlsVersionName(v uint16) string {
+	if name, ok := tlsVersions[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// httpsURL parses the announce URI and returns a notRunError if it is not an
+// https:// URL.
+func httpsURL(announceURI string) (*url.URL, error) {
+	u, err := url.Parse(announceURI)
+	if err != nil {
+		return nil, poke.WrapError("invalid announce URL", err)
+	}
+	if u.Scheme != "https" {
+		return nil, notRunError("tracker URL is not an https:// URL")
+	}
+	return u, nil
+}
+
+// tlsHandshake connects to an HTTPS tracker and reports the negotiated
+// connection and whether the tracker's certificate chain is valid.
+// An invalid chain is an error, unless the Config skips verification.
+func tlsHandshake(announceURI string, cfg Config) (TLSInfo, error) {
+	if poke.Debug {
+		log.Println("Running tlsHandshake")
+	}
+	u, err := httpsURL(announceURI)
+	if err != nil {
+		return TLSInfo{}, err
+	}
+	if cfg.Transport != nil {
+		return TLSInfo{}, notRunError("TLS tests can not be recorded or replayed")
+	}
+
+	conf := &tls.Config{}
+	if cfg.TLS != nil {
+		conf = cfg.TLS.Clone()
+	}
+	if conf.ServerName == "" {
+		conf.ServerName = u.Hostname()
+	}
+	insecure := conf.InsecureSkipVerify
+	// The chain is verified below, to report why it is invalid.
+	conf.InsecureSkipVerify = true
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: cfg.Timeout}, "tcp", addr, conf)
+	if err != nil {
+		return TLSInfo{}, poke.WrapError("unable to connect", err)
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return TLSInfo{}, errors.New("tracker did not present a certificate")
+	}
+	leaf := state.PeerCertificates[0]
+
+	info := TLSInfo{
+		Version:       tlsVersionName(state.Version),
+		CipherSuite:   tls.CipherSuiteName(state.CipherSuite),
+		ExpiresInDays: int(time.Until(leaf.NotAfter).Hours() / 24),
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         conf.RootCAs,
+		DNSName:       conf.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err = leaf.Verify(opts)
+	info.ChainValid = err == nil
+	if err != nil {
+		info.ChainError = err.Error()
+		if !insecure {
+			return info, poke.WrapError("invalid certificate chain", err)
+		}
+	}
+
+	return info, nil
+}
+
+// plainHTTPURL returns the http:// URL of an HTTPS tracker served on the
+// default port.
+func plainHTTPURL(u *url.URL) (string, error) {
+	if p := u.Port(); p != "" && p != "443" {
+		return "", notRunError("tracker URL has a non-default port")
+	}
+
+	plain := *u
+	plain.Scheme = "http"
+	plain.Host = u.Hostname()
+	if strings.Contains(plain.Host, ":") {
+		plain.Host = "[" + plain.Host + "]"
+	}
+	return plain.String(), nil
+}
+
+// tlsPlainHTTPRedirect reports whether an HTTPS tracker redirects requests via
+// plain HTTP to HTTPS.
+func tlsPlainHTTPRedirect(announceURI string, cfg Config) (string, error) {
+	if poke.Debug {
+		log.Println("Running tlsPlainHTTPRedirect")
+	}
+	u, err := httpsURL(announceURI)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Transport != nil {
+		return "", notRunError("TLS tests can not be recorded or replayed")
+	}
+
+	plain, err := plainHTTPURL(u)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := cfg.newHTTPClient(announceURI)
+	if err != nil {
+		return "", poke.WrapError("unable to create client", err)
+	}
+
+	return checkPlainHTTP(c, plain)
+}
+
+// checkPlainHTTP requests the plain HTTP URL and reports whether it redirects
+// to HTTPS.
+func checkPlainHTTP(c *http.Client, plain string) (string, error) {
+	resp, err := c.Inspect(context.Background(), plain, "")
+	if err != nil {
+		return PlainHTTPUnavailable, nil
+	}
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return PlainHTTPServed, nil
+	}
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", poke.WrapError("invalid redirect location", err)
+	}
+	if loc.Scheme != "https" {
+		return "", fmt.Errorf("plain HTTP redirects to %s instead of HTTPS", loc)
+	}
+
+	return PlainHTTPRedirects, nil
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pokehttp "github.com/mrd0ll4r/poke/http"
+	"github.com/mrd0ll4r/poke/tracker"
+)
+
+func tlsResults(t *testing.T, srv *httptest.Server, conf *tls.Config) map[string]TestResult {
+	cfg := testConfig()
+	cfg.TLS = conf
+	res, err := TestHTTPTracker(srv.URL+"/announce", cfg)
+	require.Nil(t, err)
+	checkTests(t, res.TrackerResult)
+
+	results := make(map[string]TestResult)
+	for _, test := range res.Tests {
+		if test.Run {
+			results[test.Name] = test.Result
+		}
+	}
+	return results
+}
+
+func TestHTTPSTrackerReference(t *testing.T) {
+	srv := httptest.NewTLSServer(tracker.New(tracker.DefaultConfig()))
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	results := tlsResults(t, srv, &tls.Config{RootCAs: roots, ServerName: "example.com"})
+	require.Contains(t, results, "tlsHandshake")
+	info := results["tlsHandshake"].Result.(TLSInfo)
+	assert.True(t, info.ChainValid)
+	assert.Equal(t, "TLS 1.3", info.Version)
+	assert.True(t, info.ExpiresInDays > 0)
+	// The test server does not listen on the default port.
+	assert.NotContains(t, results, "tlsPlainHTTPRedirect")
+
+	results = tlsResults(t, srv, &tls.Config{InsecureSkipVerify: true})
+	info = results["tlsHandshake"].Result.(TLSInfo)
+	assert.False(t, info.ChainValid)
+	assert.NotEmpty(t, info.ChainError)
+
+	_, err := tlsHandshake(srv.URL+"/announce", testConfig())
+	assert.NotNil(t, err)
+}
+
+func TestCheckPlainHTTP(t *testing.T) {
+	c, err := pokehttp.NewClient("https://example.com/announce")
+	require.Nil(t, err)
+
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := *r.URL
+		u.Scheme = "https"
+		u.Host = "example.com"
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	}))
+	defer redirecting.Close()
+	result, err := checkPlainHTTP(c, redirecting.URL+"/announce")
+	assert.Nil(t, err)
+	assert.Equal(t, PlainHTTPRedirects, result)
+
+	plain := httptest.NewServer(tracker.New(tracker.DefaultConfig()))
+	defer plain.Close()
+	result, err = checkPlainHTTP(c, plain.URL+"/announce")
+	assert.Nil(t, err)
+	assert.Equal(t, PlainHTTPServed, result)
+
+	insecure := httptest.NewServer(http.RedirectHandler("http://example.com/announce", http.StatusFound))
+	defer insecure.Close()
+	_, err = checkPlainHTTP(c, insecure.URL+"/announce")
+	assert.NotNil(t, err)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	result, err = checkPlainHTTP(c, closed.URL+"/announce")
+	assert.Nil(t, err)
+	assert.Equal(t, PlainHTTPUnavailable, result)
+}
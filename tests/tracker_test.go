@@ -0,0 +1,435 @@
+package tests
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/tracker"
+)
+
+// capabilities are the capabilities detected for a tracker.
+type capabilities struct {
+	announcingPeerNotInPeerList bool
+	ipSpoofing                  bool
+	optimizedSeederResponse     bool
+	scrape                      bool
+}
+
+func detected(res TrackerResult) capabilities {
+	return capabilities{
+		announcingPeerNotInPeerList: res.SupportsAnnouncingPeerNotInPeerList,
+		ipSpoofing:                  res.SupportsIPSpoofing,
+		optimizedSeederResponse:     res.SupportsOptimizedSeederResponse,
+		scrape:                      res.SupportsScrape,
+	}
+}
+
+var trackerConfigs = []struct {
+	name     string
+	modify   func(*tracker.Config)
+	expected capabilities
+}{
+	{
+		name:     "default",
+		modify:   func(*tracker.Config) {},
+		expected: capabilities{true, true, true, true},
+	},
+	{
+		name:     "announcingPeerInPeerList",
+		modify:   func(c *tracker.Config) { c.ExcludeAnnouncingPeer = false },
+		expected: capabilities{false, true, true, true},
+	},
+	{
+		name:     "noIPSpoofing",
+		modify:   func(c *tracker.Config) { c.IPSpoofing = false },
+		expected: capabilities{true, false, true, true},
+	},
+	{
+		name:     "noOptimizedSeederResponse",
+		modify:   func(c *tracker.Config) { c.OptimizedSeederResponse = false },
+		expected: capabilities{true, true, false, true},
+	},
+	{
+		name:     "noScrape",
+		modify:   func(c *tracker.Config) { c.Scrape = false },
+		expected: capabilities{true, true, true, false},
+	},
+	{
+		name: "nothing",
+		modify: func(c *tracker.Config) {
+			c.ExcludeAnnouncingPeer = false
+			c.IPSpoofing = false
+			c.OptimizedSeederResponse = false
+			c.Scrape = false
+		},
+		expected: capabilities{false, false, false, false},
+	},
+}
+
+func testConfig() Config {
+	return Config{
+		Timeout: time.Second,
+	}
+}
+
+// failingDetectors are the capability detectors that report a missing
+// capability by failing.
+var failingDetectors = map[string]bool{
+	"trackerSupportsCompactAnnounce":    true,
+	"trackerSupportsNonCompactAnnounce": true,
+	"trackerSupportsScrape":             true,
+}
+
+// checkTests checks that every test that was run succeeded, except for
+// capability detectors that fail on trackers lacking the capability.
+func checkTests(t *testing.T, res TrackerResult) {
+	for _, test := range res.Tests {
+		if !test.Run || failingDetectors[test.Name] {
+			continue
+		}
+		assert.Nil(t, test.Result.Err, test.Name)
+	}
+}
+
+func TestHTTPTrackerReference(t *testing.T) {
+	for _, tt := range trackerConfigs {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tracker.DefaultConfig()
+			tt.modify(&cfg)
+			srv := httptest.NewServer(tracker.New(cfg))
+			defer srv.Close()
+
+			res, err := TestHTTPTracker(srv.URL+"/announce", testConfig())
+			require.Nil(t, err)
+			assert.True(t, res.SupportsCompact)
+			assert.True(t, res.SupportsNonCompact)
+			assert.Equal(t, tt.expected, detected(res.TrackerResult))
+			checkTests(t, res.TrackerResult)
+		})
+	}
+}
+
+func TestHTTPTrackerReferenceCompact(t *testing.T) {
+	table := []struct {
+		compact    bool
+		nonCompact bool
+	}{
+		{true, false},
+		{false, true},
+	}
+
+	for _, tt := range table {
+		cfg := tracker.DefaultConfig()
+		cfg.Compact = tt.compact
+		cfg.NonCompact = tt.nonCompact
+		srv := httptest.NewServer(tracker.New(cfg))
+
+		res, err := TestHTTPTracker(srv.URL+"/announce", testConfig())
+		srv.Close()
+		require.Nil(t, err)
+		assert.Equal(t, tt.compact, res.SupportsCompact)
+		assert.Equal(t, tt.nonCompact, res.SupportsNonCompact)
+		assert.Equal(t, capabilities{true, true, true, true}, detected(res.TrackerResult))
+		checkTests(t, res.TrackerResult)
+	}
+}
+
+func TestHTTPTrackerIPv6Reference(t *testing.T) {
+	srv := httptest.NewServer(tracker.New(tracker.DefaultConfig()))
+	defer srv.Close()
+
+	res, err := TestHTTPTrackerIPv6(srv.URL+"/announce", testConfig())
+	require.Nil(t, err)
+	assert.True(t, res.SupportsIPv6)
+	assert.Equal(t, capabilities{true, true, true, true}, detected(res.TrackerResult))
+	checkTests(t, res.TrackerResult)
+
+	cfg := tracker.DefaultConfig()
+	cfg.IPSpoofing = false
+	srv2 := httptest.NewServer(tracker.New(cfg))
+	defer srv2.Close()
+
+	res, err = TestHTTPTrackerIPv6(srv2.URL+"/announce", testConfig())
+	require.Nil(t, err)
+	assert.False(t, res.SupportsIPv6)
+}
+
+func TestUDPTrackerReference(t *testing.T) {
+	for _, tt := range trackerConfigs {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Negative tests wait for dropped packets to time out.
+			t.Parallel()
+
+			cfg := tracker.DefaultConfig()
+			tt.modify(&cfg)
+			conn, err := tracker.New(cfg).ListenUDP("127.0.0.1:0")
+			require.Nil(t, err)
+			defer conn.Close()
+
+			res, err := TestUDPTracker(conn.LocalAddr().String(), testConfig())
+			require.Nil(t, err)
+			assert.Equal(t, tt.expected, detected(res.TrackerResult))
+			checkTests(t, res.TrackerResult)
+		})
+	}
+}
+
+// recordingPacketConn records the packets read from a net.PacketConn.
+type recordingPacketConn struct {
+	net.PacketConn
+
+	mu      sync.Mutex
+	packets [][]byte
+}
+
+func (c *recordingPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err == nil {
+		c.mu.Lock()
+		c.packets = append(c.packets, append([]byte(nil), b[:n]...))
+		c.mu.Unlock()
+	}
+	return n, addr, err
+}
+
+func TestSeedReproducible(t *testing.T) {
+	runHTTP := func(seed int64) (*HTTPResult, []string) {
+		var mu sync.Mutex
+		var requests []string
+		tr := tracker.New(tracker.DefaultConfig())
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests = append(requests, r.URL.String())
+			mu.Unlock()
+			tr.ServeHTTP(w, r)
+		}))
+		defer srv.Close()
+
+		cfg := testConfig()
+		cfg.Generator = poke.NewGenerator(seed)
+		res, err := TestHTTPTracker(srv.URL+"/announce", cfg)
+		require.Nil(t, err)
+		return res, requests
+	}
+
+	res, requests := runHTTP(42)
+	assert.Equal(t, int64(42), res.Seed)
+	require.NotEmpty(t, requests)
+
+	_, requests2 := runHTTP(42)
+	assert.Equal(t, requests, requests2)
+
+	_, requests3 := runHTTP(43)
+	assert.NotEqual(t, requests, requests3)
+
+	runUDP := func(seed int64) [][]byte {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.Nil(t, err)
+		defer conn.Close()
+		rec := &recordingPacketConn{PacketConn: conn}
+		go tracker.New(tracker.DefaultConfig()).ServeUDP(rec)
+
+		cfg := testConfig()
+		cfg.Generator = poke.NewGenerator(seed)
+		res, err := TestUDPTracker(conn.LocalAddr().String(), cfg)
+		require.Nil(t, err)
+		assert.Equal(t, seed, res.Seed)
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		for _, p := range rec.packets {
+			// Connection IDs are chosen by the tracker.
+			copy(p[:8], make([]byte, 8))
+		}
+		return rec.packets
+	}
+
+	packets := runUDP(42)
+	require.NotEmpty(t, packets)
+	assert.Equal(t, packets, runUDP(42))
+}
+
+func TestUDPConnectionIDLifecycle(t *testing.T) {
+	table := []struct {
+		ttl     time.Duration
+		expires bool
+	}{
+		{100 * time.Millisecond, true},
+		{time.Minute, false},
+	}
+
+	for _, tt := range table {
+		trackerCfg := tracker.DefaultConfig()
+		trackerCfg.ConnectionIDTTL = tt.ttl
+		conn, err := tracker.New(trackerCfg).ListenUDP("127.0.0.1:0")
+		require.Nil(t, err)
+
+		cfg := testConfig()
+		cfg.ConnectionIDExpiry = 200 * time.Millisecond
+		cfg.Run = regexp.MustCompile("ConnectionID")
+		res, err := TestUDPTracker(conn.LocalAddr().String(), cfg)
+		conn.Close()
+		require.Nil(t, err)
+		require.Equal(t, 5, len(res.Tests))
+		checkTests(t, res.TrackerResult)
+		assert.True(t, res.RejectsForgedConnectionIDs)
+		assert.True(t, res.SupportsConnectionIDReuse)
+		assert.True(t, res.BindsConnectionIDsToAddress)
+		assert.Equal(t, tt.expires, res.ExpiresConnectionIDs)
+	}
+
+	// Without a timeout, dropped packets can not be told apart from slow
+	// responses.
+	conn, err := tracker.New(tracker.DefaultConfig()).ListenUDP("127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	res, err := TestUDPTracker(conn.LocalAddr().String(), Config{Run: regexp.MustCompile("ConnectionID")})
+	require.Nil(t, err)
+	for _, test := range res.Tests {
+		assert.False(t, test.Run, test.Name)
+	}
+	assert.False(t, res.RejectsForgedConnectionIDs)
+	assert.False(t, res.BindsConnectionIDsToAddress)
+}
+
+func TestUDPURLData(t *testing.T) {
+	trackerCfg := tracker.DefaultConfig()
+	trackerCfg.UDPPath = "/secret/announce"
+	conn, err := tracker.New(trackerCfg).ListenUDP("127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+	addr := conn.LocalAddr().String()
+
+	cfg := testConfig()
+	cfg.Tags = []Tag{TagCapability}
+	res, err := TestUDPTracker("udp://"+addr+"/secret/announce?passkey=x", cfg)
+	require.Nil(t, err)
+	checkTests(t, res.TrackerResult)
+	assert.True(t, res.HonoursURLData)
+	assert.Equal(t, capabilities{true, true, true, true}, detected(res.TrackerResult))
+
+	res, err = TestUDPTracker(addr, cfg)
+	require.Nil(t, err)
+	assert.False(t, res.HonoursURLData)
+	assert.False(t, res.SupportsScrape)
+
+	conn2, err := tracker.New(tracker.DefaultConfig()).ListenUDP("127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn2.Close()
+
+	res, err = TestUDPTracker("udp://"+conn2.LocalAddr().String()+"/announce", cfg)
+	require.Nil(t, err)
+	assert.False(t, res.HonoursURLData)
+	assert.True(t, res.SupportsScrape)
+}
+
+func TestAnnounceParameters(t *testing.T) {
+	table := []struct {
+		name     string
+		modify   func(*tracker.Config)
+		noPeerID bool
+		key      bool
+	}{
+		{"default", func(*tracker.Config) {}, true, true},
+		{"noNoPeerID", func(c *tracker.Config) { c.NoPeerID = false }, false, true},
+		{"noKey", func(c *tracker.Config) { c.Key = false }, true, false},
+		{"trackerID", func(c *tracker.Config) { c.TrackerID = "poke" }, true, true},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tracker.DefaultConfig()
+			tt.modify(&cfg)
+			trk := tracker.New(cfg)
+			srv := httptest.NewServer(trk)
+			defer srv.Close()
+
+			res, err := TestHTTPTracker(srv.URL+"/announce", testConfig())
+			require.Nil(t, err)
+			assert.Equal(t, tt.noPeerID, res.HonoursNoPeerID)
+			assert.Equal(t, tt.key, res.IdentifiesPeersByKey)
+			checkTests(t, res.TrackerResult)
+
+			conn, err := trk.ListenUDP("127.0.0.1:0")
+			require.Nil(t, err)
+			defer conn.Close()
+
+			udpCfg := testConfig()
+			udpCfg.Run = regexp.MustCompile("^trackerSupportsKeyAnnounce$")
+			udpRes, err := TestUDPTracker(conn.LocalAddr().String(), udpCfg)
+			require.Nil(t, err)
+			assert.Equal(t, tt.key, udpRes.IdentifiesPeersByKey)
+			checkTests(t, udpRes.TrackerResult)
+		})
+	}
+
+	cfg := tracker.DefaultConfig()
+	cfg.IPSpoofing = false
+	srv := httptest.NewServer(tracker.New(cfg))
+	defer srv.Close()
+
+	res, err := TestHTTPTracker(srv.URL+"/announce", testConfig())
+	require.Nil(t, err)
+	assert.False(t, res.IdentifiesPeersByKey)
+	for _, test := range res.Tests {
+		if test.Name == "trackerSupportsKeyAnnounce" {
+			assert.False(t, test.Run)
+		}
+	}
+}
+
+// trailingDataHandler appends data to the responses of a tracker.
+type trailingDataHandler struct {
+	http.Handler
+}
+
+func (h trailingDataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Handler.ServeHTTP(w, r)
+	w.Write([]byte("\n"))
+}
+
+func TestStrict(t *testing.T) {
+	srv := httptest.NewServer(trailingDataHandler{tracker.New(tracker.DefaultConfig())})
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.Run = regexp.MustCompile("^basicAnnounce$")
+	res, err := TestHTTPTracker(srv.URL+"/announce", cfg)
+	require.Nil(t, err)
+	checkTests(t, res.TrackerResult)
+	require.Equal(t, "basicAnnounce", res.Tests[len(res.Tests)-1].Name)
+	for _, test := range res.Tests {
+		require.NotEmpty(t, test.Result.Findings, test.Name)
+		assert.Equal(t, "1 bytes of trailing data", test.Result.Findings[0].Message, test.Name)
+	}
+
+	cfg.Strict = true
+	res, err = TestHTTPTracker(srv.URL+"/announce", cfg)
+	require.Nil(t, err)
+	for _, test := range res.Tests {
+		if test.Run {
+			assert.NotNil(t, test.Result.Err, test.Name)
+		}
+	}
+
+	srv2 := httptest.NewServer(tracker.New(tracker.DefaultConfig()))
+	defer srv2.Close()
+
+	res, err = TestHTTPTracker(srv2.URL+"/announce", cfg)
+	require.Nil(t, err)
+	checkTests(t, res.TrackerResult)
+	for _, test := range res.Tests {
+		assert.Empty(t, test.Result.Findings, test.Name)
+	}
+}
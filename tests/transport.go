@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"strings"
+
+	"github.com/zeebo/bencode"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/http"
+)
+
+// HTTPExpectations are the expectations the transport tests check the HTTP
+// responses of a tracker against.
+type HTTPExpectations struct {
+	// ContentTypes are the allowed media types of responses.
+	// If empty, any content type is allowed.
+	ContentTypes []string
+
+	// AllowChunked allows chunked transfer encoding, which some old clients
+	// can not handle.
+	AllowChunked bool
+
+	// AllowRedirects allows redirecting announces and scrapes.
+	AllowRedirects bool
+
+	// FailureStatusCode is the status code expected for responses carrying
+	// a failure reason. If zero, 200 is expected.
+	FailureStatusCode int
+
+	// MaxBodySize is the maximum size of a response body, as transferred.
+	// If zero, bodies can be of any size.
+	MaxBodySize int
+}
+
+// DefaultHTTPExpectations returns the expectations most clients have.
+func DefaultHTTPExpectations() HTTPExpectations {
+	return HTTPExpectations{
+		ContentTypes:      []string{"text/plain", "application/octet-stream", "application/x-bittorrent"},
+		FailureStatusCode: 200,
+	}
+}
+
+// HTTPResponseInfo describes an HTTP response at the transport level.
+type HTTPResponseInfo struct {
+	StatusCode       int    `json:"statusCode"`
+	ContentType      string `json:"contentType,omitempty"`
+	ContentEncoding  string `json:"contentEncoding,omitempty"`
+	TransferEncoding string `json:"transferEncoding,omitempty"`
+	Location         string `json:"location,omitempty"`
+	BodySize         int    `json:"bodySize"`
+}
+
+func (i HTTPResponseInfo) String() string {
+	s := fmt.Sprintf("status %d, content type %q, %d bytes", i.StatusCode, i.ContentType, i.BodySize)
+	if i.ContentEncoding != "" {
+		s += ", content encoding " + i.ContentEncoding
+	}
+	if i.TransferEncoding != "" {
+		s += ", transfer encoding " + i.TransferEncoding
+	}
+	if i.Location != "" {
+		s += ", location " + i.Location
+	}
+	return s
+}
+
+func newHTTPResponseInfo(r http.Response) HTTPResponseInfo {
+	return HTTPResponseInfo{
+		StatusCode:       r.StatusCode,
+		ContentType:      r.Header.Get("Content-Type"),
+		ContentEncoding:  r.Header.Get("Content-Encoding"),
+		TransferEncoding: strings.Join(r.TransferEncoding, ", "),
+		Location:         r.Header.Get("Location"),
+		BodySize:         len(r.Body),
+	}
+}
+
+// check checks the response against the expectations.
+// If failure is set, the response must carry a failure reason, otherwise it
+// must not.
+func (e HTTPExpectations) check(r http.Response, acceptEncoding string, failure bool) error {
+	if r.StatusCode >= 300 && r.StatusCode < 400 {
+		if e.AllowRedirects {
+			return nil
+		}
+		return fmt.Errorf("tracker redirected with status %d to %s", r.StatusCode, r.Header.Get("Location"))
+	}
+
+	expectedStatus := 200
+	if failure && e.FailureStatusCode != 0 {
+		expectedStatus = e.FailureStatusCode
+	}
+	if r.StatusCode != expectedStatus {
+		return fmt.Errorf("status is %d, expected %d", r.StatusCode, expectedStatus)
+	}
+
+	if len(e.ContentTypes) > 0 {
+		ct := r.Header.Get("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return fmt.Errorf("invalid content type %q", ct)
+		}
+		allowed := false
+		for _, t := range e.ContentTypes {
+			allowed = allowed || t == mediaType
+		}
+		if !allowed {
+			return fmt.Errorf("content type is %q, expected one of %s", mediaType, strings.Join(e.ContentTypes, ", "))
+		}
+	}
+
+	for _, te := range r.TransferEncoding {
+		if te == "chunked" && !e.AllowChunked {
+			return errors.New("response uses chunked transfer encoding")
+		}
+	}
+
+	if e.MaxBodySize != 0 && len(r.Body) > e.MaxBodySize {
+		return fmt.Errorf("body of %d bytes exceeds %d bytes", len(r.Body), e.MaxBodySize)
+	}
+
+	body := r.Body
+	switch ce := r.Header.Get("Content-Encoding"); {
+	case ce == "" || ce == "identity":
+	case ce != acceptEncoding:
+		return fmt.Errorf("response has content encoding %q, which the client did not accept", ce)
+	case ce == "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return poke.WrapError("unable to decompress response", err)
+		}
+		body, err = ioutil.ReadAll(zr)
+		if err != nil {
+			return poke.WrapError("unable to decompress response", err)
+		}
+	default:
+		return fmt.Errorf("unknown content encoding %q", ce)
+	}
+
+	var resp struct {
+		FailureReason string `bencode:"failure reason"`
+	}
+	err := bencode.DecodeBytes(body, &resp)
+	if err != nil {
+		return poke.WrapError("unable to decode", err)
+	}
+
+	switch {
+	case failure && resp.FailureReason == "":
+		return errors.New("response to an invalid request has no failure reason")
+	case !failure && resp.FailureReason != "":
+		return errors.New("tracker returned error: " + resp.FailureReason)
+	}
+
+	return nil
+}
+
+// inspect performs the request for the URL and checks the response against
+// the expectations of the Config.
+func inspect(c *http.Client, cfg Config, u, acceptEncoding string, failure bool) (HTTPResponseInfo, error) {
+	if cfg.Transport != nil {
+		return HTTPResponseInfo{}, notRunError("transport tests can not be recorded or replayed")
+	}
+
+	resp, err := c.Inspect(context.Background(), u, acceptEncoding)
+	if err != nil {
+		return HTTPResponseInfo{}, err
+	}
+
+	return newHTTPResponseInfo(resp), cfg.HTTP.check(resp, acceptEncoding, failure)
+}
+
+// transportAnnounce inspects the response to a valid announce.
+func transportAnnounce(c *http.Client, cfg Config, acceptEncoding string) (HTTPResponseInfo, error) {
+	g := cfg.Generator
+	u, err := c.AnnounceRequestURL(poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     g.Peer(),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	})
+	if err != nil {
+		return HTTPResponseInfo{}, err
+	}
+
+	return inspect(c, cfg, u, acceptEncoding, false)
+}
+
+func httpAnnounceTransport(c *http.Client, cfg Config) (HTTPResponseInfo, error) {
+	if poke.Debug {
+		log.Println("Running httpAnnounceTransport")
+	}
+	return transportAnnounce(c, cfg, "")
+}
+
+func httpGzipAnnounceTransport(c *http.Client, cfg Config) (HTTPResponseInfo, error) {
+	if poke.Debug {
+		log.Println("Running httpGzipAnnounceTransport")
+	}
+	return transportAnnounce(c, cfg, "gzip")
+}
+
+func httpFailureTransport(c *http.Client, cfg Config) (HTTPResponseInfo, error) {
+	if poke.Debug {
+		log.Println("Running httpFailureTransport")
+	}
+	g := cfg.Generator
+	u, err := c.AnnounceRequestURL(poke.AnnounceRequest{
+		InfoHash: g.InfoHash()[:10],
+		Peer:     g.Peer(),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	})
+	if err != nil {
+		return HTTPResponseInfo{}, err
+	}
+
+	return inspect(c, cfg, u, "", true)
+}
+
+func httpScrapeTransport(c *http.Client, cfg Config) (HTTPResponseInfo, error) {
+	if poke.Debug {
+		log.Println("Running httpScrapeTransport")
+	}
+	u, err := c.ScrapeRequestURL(poke.ScrapeRequest{
+		InfoHashes: []poke.InfoHash{cfg.Generator.InfoHash()},
+	})
+	if err != nil {
+		return HTTPResponseInfo{}, err
+	}
+
+	return inspect(c, cfg, u, "", false)
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke/tracker"
+)
+
+// rewritingHandler serves the responses of the reference tracker through
+// rewrite.
+func rewritingHandler(rewrite func(w http.ResponseWriter, r *http.Request, body []byte)) http.Handler {
+	t := tracker.New(tracker.DefaultConfig())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		t.ServeHTTP(rec, r)
+		rewrite(w, r, rec.Body.Bytes())
+	})
+}
+
+func transportResults(t *testing.T, h http.Handler, expectations HTTPExpectations) map[string]error {
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.HTTP = expectations
+	cfg.Tags = []Tag{TagTransport}
+	res, err := TestHTTPTracker(srv.URL+"/announce", cfg)
+	require.Nil(t, err)
+
+	errs := make(map[string]error)
+	for _, test := range res.Tests {
+		if test.Run && !failingDetectors[test.Name] {
+			errs[test.Name] = test.Result.Err
+		}
+	}
+	return errs
+}
+
+func TestHTTPTransport(t *testing.T) {
+	errs := transportResults(t, tracker.New(tracker.DefaultConfig()), DefaultHTTPExpectations())
+	assert.Equal(t, map[string]error{
+		"httpAnnounceTransport":     nil,
+		"httpGzipAnnounceTransport": nil,
+		"httpFailureTransport":      nil,
+		"httpScrapeTransport":       nil,
+	}, errs)
+
+	html := rewritingHandler(func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(body)
+	})
+	errs = transportResults(t, html, DefaultHTTPExpectations())
+	assert.NotNil(t, errs["httpAnnounceTransport"])
+	errs = transportResults(t, html, HTTPExpectations{})
+	assert.Nil(t, errs["httpAnnounceTransport"])
+
+	chunked := rewritingHandler(func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Write(body[:1])
+		w.(http.Flusher).Flush()
+		w.Write(body[1:])
+	})
+	errs = transportResults(t, chunked, HTTPExpectations{})
+	assert.NotNil(t, errs["httpAnnounceTransport"])
+	errs = transportResults(t, chunked, HTTPExpectations{AllowChunked: true})
+	assert.Nil(t, errs["httpAnnounceTransport"])
+
+	gzipped := rewritingHandler(func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write(body)
+		zw.Close()
+	})
+	errs = transportResults(t, gzipped, HTTPExpectations{})
+	assert.NotNil(t, errs["httpAnnounceTransport"])
+	assert.Nil(t, errs["httpGzipAnnounceTransport"])
+
+	failureStatus := rewritingHandler(func(w http.ResponseWriter, r *http.Request, body []byte) {
+		if regexp.MustCompile("^d14:failure reason").Match(body) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write(body)
+	})
+	errs = transportResults(t, failureStatus, HTTPExpectations{})
+	assert.Nil(t, errs["httpAnnounceTransport"])
+	assert.NotNil(t, errs["httpFailureTransport"])
+	errs = transportResults(t, failureStatus, HTTPExpectations{FailureStatusCode: http.StatusBadRequest})
+	assert.Nil(t, errs["httpFailureTransport"])
+
+	ref := tracker.New(tracker.DefaultConfig())
+	redirecting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("redirected") == "" {
+			u := *r.URL
+			q := u.Query()
+			q.Set("redirected", "1")
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.String(), http.StatusFound)
+			return
+		}
+		ref.ServeHTTP(w, r)
+	})
+	errs = transportResults(t, redirecting, HTTPExpectations{})
+	assert.NotNil(t, errs["httpAnnounceTransport"])
+	errs = transportResults(t, redirecting, HTTPExpectations{AllowRedirects: true})
+	assert.Nil(t, errs["httpAnnounceTransport"])
+}
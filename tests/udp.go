@@ -0,0 +1,472 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/udp"
+)
+
+// UDP actions, as specified in BEP 15.
+const (
+	udpActionConnect = 0
+	udpActionError   = 3
+)
+
+// udpInvalidAction is an action not specified by BEP 15 or its extensions.
+const udpInvalidAction = 42
+
+// udpOversizePacketLength is the length of oversize packets, which exceeds
+// the MTU of most links.
+const udpOversizePacketLength = 4096
+
+// udpAnnounceLength is the length of an announce request without options.
+const udpAnnounceLength = 98
+
+// udpInvalidOption is an option type not specified by BEP 41.
+const udpInvalidOption = 0xff
+
+// udpProtocolID is the magic constant sent in UDP connect requests.
+const udpProtocolID = 0x41727101980
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// expectUDPRejected sends the packet via the client's RoundTripper and checks
+// that the tracker responds with an error or drops the packet.
+func expectUDPRejected(c *udp.Client, packet []byte, what string) error {
+	b, err := c.RoundTripper().RoundTrip(context.Background(), packet)
+	if err != nil {
+		if isTimeout(err) {
+			// The tracker dropped the packet.
+			return nil
+		}
+		return poke.WrapError("unable to send "+what, err)
+	}
+
+	if len(b) < 8 {
+		return fmt.Errorf("%s was answered with a packet of %d bytes", what, len(b))
+	}
+
+	if action := binary.BigEndian.Uint32(b[:4]); action != udpActionError {
+		return fmt.Errorf("%s was not rejected, tracker responded with action %d", what, action)
+	}
+
+	// The transaction ID of truncated packets might not be known.
+	if len(packet) >= 16 && !bytes.Equal(b[4:8], packet[12:16]) {
+		return fmt.Errorf("%s was rejected with a different transaction ID", what)
+	}
+
+	return nil
+}
+
+// udpAnnouncePacket connects and returns a valid announce packet using the
+// obtained connection ID.
+func udpAnnouncePacket(c *udp.Client, g *poke.Generator) ([]byte, error) {
+	connID, err := c.ManualConnect()
+	if err != nil {
+		return nil, err
+	}
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	return c.AnnouncePacket(req, connID, g.Uint32())
+}
+
+func udpTruncatedConnect(c *udp.Client, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running udpTruncatedConnect")
+	}
+	packet := make([]byte, 16)
+	binary.BigEndian.PutUint64(packet[0:8], udpProtocolID)
+	binary.BigEndian.PutUint32(packet[8:12], udpActionConnect)
+	binary.BigEndian.PutUint32(packet[12:16], g.Uint32())
+
+	return expectUDPRejected(c, packet[:12], "connect truncated to 12 bytes")
+}
+
+func udpTruncatedAnnounce(c *udp.Client, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running udpTruncatedAnnounce")
+	}
+	packet, err := udpAnnouncePacket(c, g)
+	if err != nil {
+		return err
+	}
+
+	// Cut the packet off in the middle of the peer ID.
+	return expectUDPRejected(c, packet[:46], "announce truncated to 46 bytes")
+}
+
+func udpWrongConnectionIDAnnounce(c *udp.Client, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running udpWrongConnectionIDAnnounce")
+	}
+	packet, err := udpAnnouncePacket(c, g)
+	if err != nil {
+		return err
+	}
+
+	connID := binary.BigEndian.Uint64(packet[0:8])
+	binary.BigEndian.PutUint64(packet[0:8], ^connID)
+
+	return expectUDPRejected(c, packet, "announce with wrong connection ID")
+}
+
+// udpOversizeAnnounce checks that the tracker rejects an announce padded to
+// udpOversizePacketLength bytes.
+// The padding is not a valid BEP 41 option stream: it consists of options of
+// an unspecified type with a length of 255, the last of which runs past the
+// end of the datagram. A tracker must not accept the announce, whether it
+// parses the options or not.
+func udpOversizeAnnounce(c *udp.Client, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running udpOversizeAnnounce")
+	}
+	packet, err := udpAnnouncePacket(c, g)
+	if err != nil {
+		return err
+	}
+
+	// Drop the options of the client, which end with an EndOfOptions option
+	// that would make the tracker ignore the padding.
+	padded := make([]byte, udpOversizePacketLength)
+	n := copy(padded, packet[:udpAnnounceLength])
+	for i := n; i < len(padded); i++ {
+		padded[i] = udpInvalidOption
+	}
+
+	return expectUDPRejected(c, padded, fmt.Sprintf("announce padded to %d bytes", len(padded)))
+}
+
+func udpInvalidActionRequest(c *udp.Client, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running udpInvalidActionRequest")
+	}
+	packet, err := udpAnnouncePacket(c, g)
+	if err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint32(packet[8:12], udpInvalidAction)
+
+	return expectUDPRejected(c, packet, fmt.Sprintf("request with action %d", udpInvalidAction))
+}
+
+func udpInvalidEventAnnounce(c *udp.Client, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running udpInvalidEventAnnounce")
+	}
+	connID, err := c.ManualConnect()
+	if err != nil {
+		return err
+	}
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventInvalid,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	packet, err := c.AnnouncePacket(req, connID, g.Uint32())
+	if err != nil {
+		return err
+	}
+
+	return expectUDPRejected(c, packet, "announce with invalid event")
+}
+
+func udpNumwantOverflowAnnounce(c *udp.Client, g *poke.Generator) error {
+	if poke.Debug {
+		log.Println("Running udpNumwantOverflowAnnounce")
+	}
+	packet, err := udpAnnouncePacket(c, g)
+	if err != nil {
+		return err
+	}
+
+	// Numwant is a signed 32 bit integer, where only -1 is a valid negative
+	// value.
+	binary.BigEndian.PutUint32(packet[92:96], 1<<31)
+
+	return expectUDPRejected(c, packet, "announce with numwant overflowing 32 bit integers")
+}
+
+// requireTimeout returns a notRunError if the Config has no timeout, which
+// is needed to tell dropped packets apart from slow responses.
+func requireTimeout(cfg Config) error {
+	if cfg.Timeout <= 0 {
+		return notRunError("dropped packets can not be detected without a timeout")
+	}
+	return nil
+}
+
+// announceRejected performs the announce and reports whether the tracker
+// rejected it, either with an error response or by not responding.
+func announceRejected(c poke.Announcer, req poke.AnnounceRequest) (bool, error) {
+	resp, err := c.Announce(req)
+	if err != nil {
+		if isTimeout(err) {
+			return true, nil
+		}
+		return false, poke.WrapError("unable to perform announce", err)
+	}
+
+	_, rejected := resp.(poke.ErrorResponse)
+	return rejected, nil
+}
+
+// newConnectedUDPClient creates a client that uses a connection ID obtained
+// once, instead of connecting for every announce.
+// The connection ID is returned along with the client.
+func newConnectedUDPClient(addr string, cfg Config) (*udp.Client, uint64, error) {
+	c, err := cfg.newUDPClient(addr)
+	if err != nil {
+		return nil, 0, poke.WrapError("unable to create client", err)
+	}
+
+	connID, err := c.ManualConnect()
+	if err != nil {
+		c.Close()
+		return nil, 0, err
+	}
+	c.SetAutoConnect(false)
+	c.SetConnectionID(connID)
+
+	return c, connID, nil
+}
+
+// announceAccepted performs an announce that the tracker must accept.
+func announceAccepted(c poke.Announcer, req poke.AnnounceRequest, what string) error {
+	rejected, err := announceRejected(c, req)
+	if err != nil {
+		return err
+	}
+	if rejected {
+		return errors.New(what + " was rejected")
+	}
+	return nil
+}
+
+// udpRejectsForgedConnectionIDAnnounce reports whether the tracker rejects
+// announces with a connection ID it did not hand out.
+func udpRejectsForgedConnectionIDAnnounce(addr string, cfg Config) (bool, error) {
+	g := cfg.Generator
+	if poke.Debug {
+		log.Println("Running udpRejectsForgedConnectionIDAnnounce")
+	}
+	if err := requireTimeout(cfg); err != nil {
+		return false, err
+	}
+	c, err := cfg.newUDPClient(addr)
+	if err != nil {
+		return false, poke.WrapError("unable to create client", err)
+	}
+	defer c.Close()
+	c.SetAutoConnect(false)
+	c.SetConnectionID(uint64(g.Uint32())<<32 | uint64(g.Uint32()))
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	return announceRejected(c, req)
+}
+
+// udpConnectionIDReuseAnnounce reports whether the tracker accepts a
+// connection ID for more than one request, as BEP 15 requires for one minute.
+func udpConnectionIDReuseAnnounce(addr string, cfg Config) (bool, error) {
+	g := cfg.Generator
+	if poke.Debug {
+		log.Println("Running udpConnectionIDReuseAnnounce")
+	}
+	if err := requireTimeout(cfg); err != nil {
+		return false, err
+	}
+	c, _, err := newConnectedUDPClient(addr, cfg)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	err = announceAccepted(c, req, "announce with new connection ID")
+	if err != nil {
+		return false, err
+	}
+
+	req.Event = poke.EventNone
+	req.Left = 50
+
+	rejected, err := announceRejected(c, req)
+	return !rejected, err
+}
+
+// udpConnectionIDBoundToAddressAnnounce reports whether the tracker rejects
+// connection IDs sent from a different address or port than the one they
+// were handed out to.
+func udpConnectionIDBoundToAddressAnnounce(addr string, cfg Config) (bool, error) {
+	g := cfg.Generator
+	if poke.Debug {
+		log.Println("Running udpConnectionIDBoundToAddressAnnounce")
+	}
+	if err := requireTimeout(cfg); err != nil {
+		return false, err
+	}
+	c, connID, err := newConnectedUDPClient(addr, cfg)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	err = announceAccepted(c, req, "announce with new connection ID")
+	if err != nil {
+		return false, err
+	}
+
+	// The new client uses a different local port.
+	other, err := cfg.newUDPClient(addr)
+	if err != nil {
+		return false, poke.WrapError("unable to create client", err)
+	}
+	defer other.Close()
+	other.SetAutoConnect(false)
+	other.SetConnectionID(connID)
+
+	req.Peer = newPeer(other, g)
+
+	return announceRejected(other, req)
+}
+
+// udpConnectionIDExpiryAnnounce reports whether the tracker rejects a
+// connection ID after cfg.ConnectionIDExpiry.
+func udpConnectionIDExpiryAnnounce(addr string, cfg Config) (bool, error) {
+	g := cfg.Generator
+	if poke.Debug {
+		log.Println("Running udpConnectionIDExpiryAnnounce")
+	}
+	if cfg.ConnectionIDExpiry <= 0 {
+		return false, notRunError("waiting for connection IDs to expire is disabled")
+	}
+	if err := requireTimeout(cfg); err != nil {
+		return false, err
+	}
+
+	c, _, err := newConnectedUDPClient(addr, cfg)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	err = announceAccepted(c, req, "announce with new connection ID")
+	if err != nil {
+		return false, err
+	}
+
+	time.Sleep(cfg.ConnectionIDExpiry)
+
+	req.Event = poke.EventNone
+	req.Left = 50
+
+	return announceRejected(c, req)
+}
+
+// udpURLDataAnnounce reports whether the tracker honours the path and query
+// of its URL, which are sent as BEP 41 URL data, by checking that announces
+// with a different path are rejected.
+func udpURLDataAnnounce(addr string, cfg Config) (bool, error) {
+	g := cfg.Generator
+	if poke.Debug {
+		log.Println("Running udpURLDataAnnounce")
+	}
+	if err := requireTimeout(cfg); err != nil {
+		return false, err
+	}
+	if !strings.HasPrefix(addr, "udp://") {
+		return false, notRunError("tracker address is not a udp:// URL")
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return false, poke.WrapError("invalid tracker URL", err)
+	}
+	if u.Path == "" && u.RawQuery == "" {
+		return false, notRunError("tracker URL has no path or query")
+	}
+
+	c, err := cfg.newUDPClient(addr)
+	if err != nil {
+		return false, poke.WrapError("unable to create client", err)
+	}
+	defer c.Close()
+
+	req := poke.AnnounceRequest{
+		InfoHash: g.InfoHash(),
+		Peer:     newPeer(c, g),
+		Event:    poke.EventStarted,
+		Numwant:  50,
+		Left:     100,
+	}
+
+	err = announceAccepted(c, req, "announce with URL data")
+	if err != nil {
+		return false, err
+	}
+
+	u.Path = fmt.Sprintf("/poke%08x", g.Uint32())
+	u.RawQuery = ""
+	other, err := cfg.newUDPClient(u.String())
+	if err != nil {
+		return false, poke.WrapError("unable to create client", err)
+	}
+	defer other.Close()
+
+	req.Peer = newPeer(other, g)
+
+	return announceRejected(other, req)
+}
@@ -0,0 +1,226 @@
+package tracker
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zeebo/bencode"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// ServeHTTP serves HTTP announces and scrapes.
+//
+// Announces are served on paths ending in /announce, scrapes on paths ending
+// in /scrape.
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var resp map[string]interface{}
+	switch {
+	case !t.validPasskey(r):
+		resp = failure("unknown passkey")
+	case strings.HasSuffix(r.URL.Path, "/announce"):
+		resp = t.serveHTTPAnnounce(r)
+	case strings.HasSuffix(r.URL.Path, "/scrape") && t.cfg.Scrape:
+		resp = t.serveHTTPScrape(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	b, err := bencode.EncodeBytes(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write(b)
+}
+
+// validPasskey reports whether the request carries a known passkey, if the
+// tracker is private.
+func (t *Tracker) validPasskey(r *http.Request) bool {
+	if len(t.cfg.Passkeys) == 0 {
+		return true
+	}
+
+	candidates := append(strings.Split(r.URL.Path, "/"), r.URL.Query().Get("passkey"))
+	for _, c := range candidates {
+		for _, p := range t.cfg.Passkeys {
+			if c != "" && c == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func failure(reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"failure reason": reason,
+	}
+}
+
+func (t *Tracker) serveHTTPAnnounce(r *http.Request) map[string]interface{} {
+	q := r.URL.Query()
+
+	req := poke.AnnounceRequest{
+		InfoHash: poke.InfoHash(q.Get("info_hash")),
+		Numwant:  DefaultNumwant,
+		Peer: poke.Peer{
+			ID: q.Get("peer_id"),
+		},
+	}
+
+	port, err := strconv.ParseUint(q.Get("port"), 10, 16)
+	if err != nil {
+		return failure("invalid port")
+	}
+	req.Port = uint16(port)
+
+	for _, f := range []struct {
+		name string
+		to   *int
+	}{
+		{"uploaded", &req.Uploaded},
+		{"downloaded", &req.Downloaded},
+		{"left", &req.Left},
+	} {
+		*f.to, err = strconv.Atoi(q.Get(f.name))
+		if err != nil {
+			return failure("invalid " + f.name)
+		}
+	}
+
+	if key := q.Get("key"); key != "" {
+		k, err := strconv.ParseUint(key, 16, 32)
+		if err != nil {
+			return failure("invalid key")
+		}
+		req.Key = uint32(k)
+	}
+
+	if numwant := q.Get("numwant"); numwant != "" {
+		req.Numwant, err = strconv.Atoi(numwant)
+		if err != nil {
+			return failure("invalid numwant")
+		}
+	}
+
+	switch q.Get("event") {
+	case "":
+		req.Event = poke.EventNone
+	case "started":
+		req.Event = poke.EventStarted
+	case "stopped":
+		req.Event = poke.EventStopped
+	case "completed":
+		req.Event = poke.EventCompleted
+	default:
+		req.Event = poke.EventInvalid
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return failure("invalid remote address")
+	}
+	provided := net.ParseIP(q.Get("ip"))
+	if ipv6 := net.ParseIP(q.Get("ipv6")); ipv6 != nil {
+		provided = ipv6
+	}
+	req.IP = t.peerIP(net.ParseIP(host), provided)
+
+	resp, err := t.Announce(req)
+	if err != nil {
+		return failure(err.Error())
+	}
+
+	switch resp := resp.(type) {
+	case poke.ErrorResponse:
+		return failure(string(resp))
+	case poke.AnnounceResponse:
+		compact := (q.Get("compact") == "1" && t.cfg.Compact) || !t.cfg.NonCompact
+		noPeerID := q.Get("no_peer_id") == "1" && t.cfg.NoPeerID
+		m := httpAnnounceResponse(resp, compact, noPeerID)
+		if t.cfg.TrackerID != "" {
+			m["tracker id"] = t.cfg.TrackerID
+		}
+		return m
+	}
+
+	return failure("internal error")
+}
+
+func httpAnnounceResponse(resp poke.AnnounceResponse, compact, noPeerID bool) map[string]interface{} {
+	m := map[string]interface{}{
+		"interval":     resp.Interval,
+		"min interval": resp.MinInterval,
+		"complete":     resp.Complete,
+		"incomplete":   resp.Incomplete,
+	}
+
+	if !compact {
+		peers := make([]interface{}, 0, len(resp.Peers))
+		for _, p := range resp.Peers {
+			entry := map[string]interface{}{
+				"ip":   p.IP.String(),
+				"port": p.Port,
+			}
+			if !noPeerID {
+				entry["peer id"] = p.ID
+			}
+			peers = append(peers, entry)
+		}
+		m["peers"] = peers
+		return m
+	}
+
+	var peers, peers6 []byte
+	for _, p := range resp.Peers {
+		port := []byte{byte(p.Port >> 8), byte(p.Port)}
+		if ip := p.IP.To4(); ip != nil {
+			peers = append(append(peers, ip...), port...)
+		} else {
+			peers6 = append(append(peers6, p.IP.To16()...), port...)
+		}
+	}
+	m["peers"] = string(peers)
+	if len(peers6) > 0 {
+		m["peers6"] = string(peers6)
+	}
+
+	return m
+}
+
+func (t *Tracker) serveHTTPScrape(r *http.Request) map[string]interface{} {
+	req := poke.ScrapeRequest{}
+	for _, ih := range r.URL.Query()["info_hash"] {
+		req.InfoHashes = append(req.InfoHashes, poke.InfoHash(ih))
+	}
+
+	resp, err := t.Scrape(req)
+	if err != nil {
+		return failure(err.Error())
+	}
+
+	switch resp := resp.(type) {
+	case poke.ErrorResponse:
+		return failure(string(resp))
+	case poke.ScrapeResponse:
+		files := make(map[string]interface{})
+		for _, f := range resp.Files {
+			files[string(f.InfoHash)] = map[string]interface{}{
+				"complete":   f.Complete,
+				"downloaded": f.Downloaded,
+				"incomplete": f.Incomplete,
+			}
+		}
+		return map[string]interface{}{
+			"files": files,
+		}
+	}
+
+	return failure("internal error")
+}
@@ -0,0 +1,302 @@
+// Package tracker implements a small in-memory BitTorrent tracker, serving
+// HTTP and UDP announces and scrapes.
+//
+// The tracker is meant as a reference to test poke against. Its behaviour can
+// be configured to match the features poke detects.
+package tracker
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// DefaultNumwant is the number of peers returned if the announce did not
+// specify a valid numwant.
+const DefaultNumwant = 50
+
+// Config configures the behaviour of a Tracker.
+type Config struct {
+	// Compact enables compact HTTP announce responses.
+	Compact bool
+
+	// NonCompact enables non-compact HTTP announce responses.
+	//
+	// If only one of Compact and NonCompact is enabled, that format is used
+	// for all HTTP announce responses.
+	NonCompact bool
+
+	// IPSpoofing enables using the IP address provided by the client instead
+	// of the address the request came from.
+	IPSpoofing bool
+
+	// OptimizedSeederResponse enables leaving out other seeders from the
+	// peer lists returned to seeders.
+	OptimizedSeederResponse bool
+
+	// ExcludeAnnouncingPeer enables leaving out the announcing peer from the
+	// returned peer list.
+	ExcludeAnnouncingPeer bool
+
+	// Scrape enables scrapes.
+	Scrape bool
+
+	// NoPeerID enables leaving out peer IDs from non-compact HTTP announce
+	// responses to clients that send no_peer_id.
+	NoPeerID bool
+
+	// Key enables identifying peers by their key: announces for a known
+	// peer ID with a different key are rejected.
+	Key bool
+
+	// TrackerID, if not empty, is returned as the tracker id of HTTP
+	// announce responses.
+	TrackerID string
+
+	// Passkeys, if not empty, makes the tracker private: HTTP announces and
+	// scrapes must carry one of the passkeys as a path segment or as the
+	// passkey query parameter.
+	Passkeys []string
+
+	// InfoHashes, if not empty, are the registered torrents. Announces for
+	// other infohashes are rejected.
+	InfoHashes []poke.InfoHash
+
+	// Interval is the announce interval returned to clients.
+	Interval time.Duration
+
+	// MinInterval is the minimum announce interval returned to clients.
+	MinInterval time.Duration
+
+	// ConnectionIDTTL is the time a UDP connection ID is valid for.
+	ConnectionIDTTL time.Duration
+
+	// UDPPath, if not empty, is the path UDP announces must carry as BEP 41
+	// URL data, like a tracker that routes announces on their path.
+	UDPPath string
+}
+
+// DefaultConfig returns a Config with all features enabled.
+func DefaultConfig() Config {
+	return Config{
+		Compact:                 true,
+		NonCompact:              true,
+		IPSpoofing:              true,
+		OptimizedSeederResponse: true,
+		ExcludeAnnouncingPeer:   true,
+		Scrape:                  true,
+		NoPeerID:                true,
+		Key:                     true,
+		Interval:                30 * time.Minute,
+		MinInterval:             15 * time.Minute,
+		ConnectionIDTTL:         2 * time.Minute,
+	}
+}
+
+type peer struct {
+	poke.Peer
+	seeder bool
+	key    uint32
+}
+
+type swarm struct {
+	// Peers are kept in the order they first announced, to make responses
+	// deterministic.
+	peers      []peer
+	downloaded int
+}
+
+func (s *swarm) find(id string) int {
+	for i, p := range s.peers {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *swarm) counts() (complete, incomplete int) {
+	for _, p := range s.peers {
+		if p.seeder {
+			complete++
+		} else {
+			incomplete++
+		}
+	}
+	return
+}
+
+// Tracker is an in-memory BitTorrent tracker.
+//
+// It implements poke.Announcer and poke.Scraper directly, the HTTP and UDP
+// frontends translate requests into calls to these.
+type Tracker struct {
+	cfg Config
+
+	mu     sync.Mutex
+	swarms map[string]*swarm
+
+	connMu sync.Mutex
+	conns  map[uint64]connection
+}
+
+var (
+	_ poke.Announcer = &Tracker{}
+	_ poke.Scraper   = &Tracker{}
+)
+
+// New creates a new Tracker with the given Config.
+func New(cfg Config) *Tracker {
+	return &Tracker{
+		cfg:    cfg,
+		swarms: make(map[string]*swarm),
+		conns:  make(map[uint64]connection),
+	}
+}
+
+// Announce processes an announce.
+//
+// The peer's IP address must already be set to the address the tracker should
+// use for the peer. Invalid announces result in a poke.ErrorResponse.
+func (t *Tracker) Announce(req poke.AnnounceRequest) (poke.OptionalAnnounceResponse, error) {
+	switch {
+	case len(req.InfoHash) != 20:
+		return poke.ErrorResponse("invalid infohash"), nil
+	case len(req.ID) != 20:
+		return poke.ErrorResponse("invalid peer ID"), nil
+	case req.Uploaded < 0:
+		return poke.ErrorResponse("invalid uploaded"), nil
+	case req.Downloaded < 0:
+		return poke.ErrorResponse("invalid downloaded"), nil
+	case req.Left < 0:
+		return poke.ErrorResponse("invalid left"), nil
+	}
+
+	switch req.Event {
+	case poke.EventStarted, poke.EventStopped, poke.EventCompleted, poke.EventNone:
+	default:
+		return poke.ErrorResponse("invalid event"), nil
+	}
+
+	if !t.registered(req.InfoHash) {
+		return poke.ErrorResponse("unregistered torrent"), nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.swarms[string(req.InfoHash)]
+	if !ok {
+		s = &swarm{}
+		t.swarms[string(req.InfoHash)] = s
+	}
+
+	resp := poke.AnnounceResponse{
+		Interval:    int(t.cfg.Interval / time.Second),
+		MinInterval: int(t.cfg.MinInterval / time.Second),
+		Peers:       make([]poke.Peer, 0),
+	}
+
+	i := s.find(req.ID)
+	if t.cfg.Key && i >= 0 && s.peers[i].key != 0 && s.peers[i].key != req.Key {
+		return poke.ErrorResponse("invalid key"), nil
+	}
+
+	if req.Event == poke.EventStopped {
+		if i >= 0 {
+			s.peers = append(s.peers[:i], s.peers[i+1:]...)
+		}
+		resp.Complete, resp.Incomplete = s.counts()
+		return resp, nil
+	}
+
+	if req.Event == poke.EventCompleted {
+		s.downloaded++
+	}
+
+	p := peer{
+		Peer:   req.Peer,
+		seeder: req.Left == 0,
+		key:    req.Key,
+	}
+	if i >= 0 {
+		s.peers[i] = p
+	} else {
+		s.peers = append(s.peers, p)
+	}
+
+	numwant := req.Numwant
+	if numwant <= 0 {
+		numwant = DefaultNumwant
+	}
+
+	for _, other := range s.peers {
+		if len(resp.Peers) >= numwant {
+			break
+		}
+		if t.cfg.ExcludeAnnouncingPeer && other.ID == req.ID {
+			continue
+		}
+		if t.cfg.OptimizedSeederResponse && p.seeder && other.seeder {
+			continue
+		}
+		resp.Peers = append(resp.Peers, other.Peer)
+	}
+
+	resp.Complete, resp.Incomplete = s.counts()
+	return resp, nil
+}
+
+// Scrape processes a scrape.
+//
+// Unknown infohashes are reported with all counters set to zero.
+// If scrapes are disabled, a poke.ErrorResponse is returned.
+func (t *Tracker) Scrape(req poke.ScrapeRequest) (poke.OptionalScrapeResponse, error) {
+	if !t.cfg.Scrape {
+		return poke.ErrorResponse("scrape not supported"), nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resp := poke.ScrapeResponse{
+		Files: make([]poke.Scrape, 0, len(req.InfoHashes)),
+	}
+	for _, ih := range req.InfoHashes {
+		f := poke.Scrape{
+			InfoHash: ih,
+		}
+		if s, ok := t.swarms[string(ih)]; ok {
+			f.Complete, f.Incomplete = s.counts()
+			f.Downloaded = s.downloaded
+		}
+		resp.Files = append(resp.Files, f)
+	}
+
+	return resp, nil
+}
+
+// registered reports whether announces for the infohash are served.
+func (t *Tracker) registered(infoHash poke.InfoHash) bool {
+	if len(t.cfg.InfoHashes) == 0 {
+		return true
+	}
+	for _, ih := range t.cfg.InfoHashes {
+		if bytes.Equal(ih, infoHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIP determines the IP address to use for a peer, given the address the
+// request came from and the address provided by the client, if any.
+func (t *Tracker) peerIP(remote, provided net.IP) net.IP {
+	if t.cfg.IPSpoofing && provided != nil && !provided.IsUnspecified() {
+		return provided
+	}
+	return remote
+}
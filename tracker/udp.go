@@ -0,0 +1,268 @@
+package tracker
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mrd0ll4r/poke"
+	"github.com/mrd0ll4r/poke/udp"
+)
+
+// protocolID is the magic constant sent in UDP connect requests.
+const protocolID = 0x41727101980
+
+// UDP actions, as specified in BEP 15.
+const (
+	actionConnect  = 0
+	actionAnnounce = 1
+	actionScrape   = 2
+	actionError    = 3
+)
+
+// maxUDPPacketSize is the size of the largest packet the tracker accepts.
+// Larger packets are dropped.
+const maxUDPPacketSize = 2048
+
+// connection is a UDP connection ID handed out to a client.
+type connection struct {
+	addr   string
+	issued time.Time
+}
+
+// ListenUDP listens for UDP packets on addr and serves them in the
+// background, until the returned connection is closed.
+func (t *Tracker) ListenUDP(addr string) (net.PacketConn, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	go t.ServeUDP(conn)
+
+	return conn, nil
+}
+
+// ServeUDP serves UDP connects, announces and scrapes received on conn.
+// It returns when reading from conn fails, for example because it was closed.
+func (t *Tracker) ServeUDP(conn net.PacketConn) error {
+	// Read packets up to the maximum datagram size, to detect oversize
+	// packets.
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		resp := t.handleUDP(buf[:n], addr)
+		if resp == nil {
+			continue
+		}
+
+		_, err = conn.WriteTo(resp, addr)
+		if err != nil {
+			poke.Debugf("tracker: unable to write UDP response: %s\n", err)
+		}
+	}
+}
+
+// handleUDP handles a single UDP packet and returns the response, or nil if
+// the packet should be dropped.
+func (t *Tracker) handleUDP(packet []byte, addr net.Addr) []byte {
+	if len(packet) < 16 || len(packet) > maxUDPPacketSize {
+		return nil
+	}
+
+	connID := binary.BigEndian.Uint64(packet[0:8])
+	action := binary.BigEndian.Uint32(packet[8:12])
+	transactionID := binary.BigEndian.Uint32(packet[12:16])
+
+	if action == actionConnect {
+		if connID != protocolID {
+			return udpError(transactionID, "invalid protocol ID")
+		}
+		resp := make([]byte, 16)
+		binary.BigEndian.PutUint32(resp[4:8], transactionID)
+		binary.BigEndian.PutUint64(resp[8:16], t.newConnectionID(addr))
+		return resp
+	}
+
+	if !t.validConnectionID(connID, addr) {
+		return udpError(transactionID, "invalid connection ID")
+	}
+
+	switch action {
+	case actionAnnounce:
+		return t.handleUDPAnnounce(packet, addr, transactionID)
+	case actionScrape:
+		return t.handleUDPScrape(packet, transactionID)
+	}
+
+	return udpError(transactionID, "invalid action")
+}
+
+func (t *Tracker) newConnectionID(addr net.Addr) uint64 {
+	t.connMu.Lock()
+	defer t.connMu.Unlock()
+
+	// Expire old connection IDs.
+	now := time.Now()
+	for id, c := range t.conns {
+		if now.Sub(c.issued) > t.cfg.ConnectionIDTTL {
+			delete(t.conns, id)
+		}
+	}
+
+	id := uint64(rand.Int63())
+	t.conns[id] = connection{
+		addr:   addr.String(),
+		issued: now,
+	}
+	return id
+}
+
+func (t *Tracker) validConnectionID(id uint64, addr net.Addr) bool {
+	t.connMu.Lock()
+	defer t.connMu.Unlock()
+
+	c, ok := t.conns[id]
+	if !ok {
+		return false
+	}
+
+	return c.addr == addr.String() && time.Since(c.issued) <= t.cfg.ConnectionIDTTL
+}
+
+func udpError(transactionID uint32, msg string) []byte {
+	resp := make([]byte, 8, 8+len(msg))
+	binary.BigEndian.PutUint32(resp[0:4], actionError)
+	binary.BigEndian.PutUint32(resp[4:8], transactionID)
+	return append(resp, msg...)
+}
+
+func (t *Tracker) handleUDPAnnounce(packet []byte, addr net.Addr, transactionID uint32) []byte {
+	if len(packet) < 98 {
+		return udpError(transactionID, "malformed packet")
+	}
+
+	req := poke.AnnounceRequest{
+		InfoHash:   poke.InfoHash(append([]byte(nil), packet[16:36]...)),
+		Downloaded: int(int64(binary.BigEndian.Uint64(packet[56:64]))),
+		Left:       int(int64(binary.BigEndian.Uint64(packet[64:72]))),
+		Uploaded:   int(int64(binary.BigEndian.Uint64(packet[72:80]))),
+		Numwant:    int(int32(binary.BigEndian.Uint32(packet[92:96]))),
+		Key:        binary.BigEndian.Uint32(packet[88:92]),
+		Peer: poke.Peer{
+			ID:   string(packet[36:56]),
+			Port: binary.BigEndian.Uint16(packet[96:98]),
+		},
+	}
+
+	if t.cfg.UDPPath != "" {
+		urlData, err := udp.ParseURLData(packet[98:])
+		if err != nil {
+			return udpError(transactionID, err.Error())
+		}
+		if path := strings.SplitN(urlData, "?", 2)[0]; path != t.cfg.UDPPath {
+			return udpError(transactionID, "unknown path")
+		}
+	}
+
+	// Only -1 is a valid negative numwant, meaning the default.
+	if req.Numwant < -1 {
+		return udpError(transactionID, "invalid numwant")
+	}
+
+	switch binary.BigEndian.Uint32(packet[80:84]) {
+	case 0:
+		req.Event = poke.EventNone
+	case 1:
+		req.Event = poke.EventCompleted
+	case 2:
+		req.Event = poke.EventStarted
+	case 3:
+		req.Event = poke.EventStopped
+	default:
+		req.Event = poke.EventInvalid
+	}
+
+	remote := addr.(*net.UDPAddr).IP
+	ipv6 := remote.To4() == nil
+	var provided net.IP
+	if !ipv6 {
+		provided = net.IP(append([]byte(nil), packet[84:88]...))
+	}
+	req.IP = t.peerIP(remote, provided)
+
+	resp, err := t.Announce(req)
+	if err != nil {
+		return udpError(transactionID, err.Error())
+	}
+
+	switch resp := resp.(type) {
+	case poke.ErrorResponse:
+		return udpError(transactionID, string(resp))
+	case poke.AnnounceResponse:
+		b := make([]byte, 20, 20+18*len(resp.Peers))
+		binary.BigEndian.PutUint32(b[0:4], actionAnnounce)
+		binary.BigEndian.PutUint32(b[4:8], transactionID)
+		binary.BigEndian.PutUint32(b[8:12], uint32(resp.Interval))
+		binary.BigEndian.PutUint32(b[12:16], uint32(resp.Incomplete))
+		binary.BigEndian.PutUint32(b[16:20], uint32(resp.Complete))
+
+		// Only peers of the address family used to announce are returned.
+		for _, p := range resp.Peers {
+			ip := p.IP.To4()
+			if ipv6 {
+				if ip != nil {
+					continue
+				}
+				ip = p.IP.To16()
+			} else if ip == nil {
+				continue
+			}
+			b = append(append(b, ip...), byte(p.Port>>8), byte(p.Port))
+		}
+		return b
+	}
+
+	return udpError(transactionID, "internal error")
+}
+
+func (t *Tracker) handleUDPScrape(packet []byte, transactionID uint32) []byte {
+	if (len(packet)-16)%20 != 0 || len(packet) == 16 {
+		return udpError(transactionID, "malformed packet")
+	}
+
+	req := poke.ScrapeRequest{}
+	for i := 16; i < len(packet); i += 20 {
+		req.InfoHashes = append(req.InfoHashes, poke.InfoHash(append([]byte(nil), packet[i:i+20]...)))
+	}
+
+	resp, err := t.Scrape(req)
+	if err != nil {
+		return udpError(transactionID, err.Error())
+	}
+
+	switch resp := resp.(type) {
+	case poke.ErrorResponse:
+		return udpError(transactionID, string(resp))
+	case poke.ScrapeResponse:
+		b := make([]byte, 8, 8+12*len(resp.Files))
+		binary.BigEndian.PutUint32(b[0:4], actionScrape)
+		binary.BigEndian.PutUint32(b[4:8], transactionID)
+		for _, f := range resp.Files {
+			triple := make([]byte, 12)
+			binary.BigEndian.PutUint32(triple[0:4], uint32(f.Complete))
+			binary.BigEndian.PutUint32(triple[4:8], uint32(f.Downloaded))
+			binary.BigEndian.PutUint32(triple[8:12], uint32(f.Incomplete))
+			b = append(b, triple...)
+		}
+		return b
+	}
+
+	return udpError(transactionID, "internal error")
+}
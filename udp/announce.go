@@ -2,6 +2,7 @@ package udp
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -10,29 +11,43 @@ import (
 	"net"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/mrd0ll4r/poke"
 )
 
-func init() {
-	var transid = rand.Uint32()
-	tid = &transid
-}
-
 // ErrInvalidAddress indicates an invalid address was used to create a client.
 var ErrInvalidAddress = errors.New("invalid address")
 
-var tid *uint32
+// ErrMalformedOptions indicates that the options following an announce could
+// not be parsed.
+var ErrMalformedOptions = errors.New("malformed options")
+
+// DefaultTimeout is the default time to wait for the first response to a
+// request, as specified in BEP 15.
+const DefaultTimeout = 15 * time.Second
 
 // Client is a UDP client.
 type Client struct {
 	addr         string
+	urlData      string
 	conn         net.Conn
 	connectionID uint64
 	autoConnect  bool
+	ipv6         bool
+	timeout      time.Duration
+	retries      int
+	roundTripper poke.RoundTripper
+
+	// transactionID is the transaction ID of the next request.
+	// It is accessed atomically.
+	transactionID uint32
 }
 
-var _ poke.Announcer = &Client{}
+var (
+	_ poke.Announcer        = &Client{}
+	_ poke.ContextAnnouncer = &Client{}
+)
 
 // SetAutoConnect enables or disables the automatic creation of connection IDs.
 func (c *Client) SetAutoConnect(to bool) {
@@ -46,29 +61,227 @@ func (c *Client) SetConnectionID(to uint64) {
 	c.connectionID = to
 }
 
+// SetTransactionID sets the transaction ID of the next request.
+// Every request increments the transaction ID.
+//
+// This makes the requests sent by a client reproducible, the initial
+// transaction ID is random otherwise.
+func (c *Client) SetTransactionID(to uint32) {
+	atomic.StoreUint32(&c.transactionID, to)
+}
+
+// nextTransactionID returns the transaction ID to use for a request.
+func (c *Client) nextTransactionID() uint32 {
+	return atomic.AddUint32(&c.transactionID, 1) - 1
+}
+
+// SetTimeout sets the time to wait for the first response to a request.
+//
+// Every retransmission doubles the time to wait, as specified in BEP 15.
+// A timeout of zero or less waits for responses without a time limit, so
+// requests are never retransmitted.
+func (c *Client) SetTimeout(to time.Duration) {
+	c.timeout = to
+}
+
+// SetRetries sets the number of times a request is retransmitted if no
+// response was received.
+func (c *Client) SetRetries(to int) {
+	c.retries = to
+}
+
 // NewClient creates a new client for the given tracker address.
 //
+// The address is either host:port, or a URL of the form
+// udp://host:port/path?query. The path and query of a URL are sent with every
+// announce as URL data, as specified in BEP 41.
+//
 // The Client will automatically make connect requests for every announce.
+// It waits DefaultTimeout for responses and does not retransmit requests.
 func NewClient(addr string) (*Client, error) {
+	addr, urlData, err := parseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+
 	conn, err := net.Dial("udp", addr)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{
-		addr:        addr,
-		conn:        conn,
-		autoConnect: true,
-	}, nil
+	var ipv6 bool
+	if udpAddr, ok := conn.RemoteAddr().(*net.UDPAddr); ok {
+		ipv6 = udpAddr.IP.To4() == nil
+	}
+
+	c := &Client{
+		addr:          addr,
+		urlData:       urlData,
+		conn:          conn,
+		autoConnect:   true,
+		ipv6:          ipv6,
+		timeout:       DefaultTimeout,
+		transactionID: rand.Uint32(),
+	}
+	c.roundTripper = poke.RoundTripperFunc(c.roundTrip)
+
+	return c, nil
+}
+
+// Close closes the socket of the client.
+// The client can not be used afterwards.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// SetRoundTripper sets the RoundTripper used to exchange datagrams with the
+// tracker.
+func (c *Client) SetRoundTripper(rt poke.RoundTripper) {
+	c.roundTripper = rt
+}
+
+// RoundTripper returns the RoundTripper used to exchange datagrams with the
+// tracker.
+// By default, datagrams are sent to the tracker and retransmitted as
+// configured.
+func (c *Client) RoundTripper() poke.RoundTripper {
+	return c.roundTripper
+}
+
+// IPv6 reports whether the client talks to the tracker via IPv6.
+//
+// As specified in BEP 15, announce responses received via IPv6 contain IPv6
+// peers.
+func (c *Client) IPv6() bool {
+	return c.ipv6
+}
+
+// roundTrip sends the packet and waits for a response.
+//
+// If no response is received within the timeout, the packet is retransmitted
+// up to c.retries times, doubling the timeout every time.
+// Waiting for a response is aborted if ctx is done.
+func (c *Client) roundTrip(ctx context.Context, packet []byte) ([]byte, error) {
+	// Unblock pending reads if the context is done.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.conn.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	buf := make([]byte, 2048)
+	for i := 0; ; i++ {
+		n, err := c.conn.Write(packet)
+		if err != nil {
+			return nil, err
+		}
+
+		if n != len(packet) {
+			return nil, fmt.Errorf("did not send %d bytes", len(packet))
+		}
+
+		// The zero deadline waits without a time limit.
+		var deadline time.Time
+		if c.timeout > 0 {
+			deadline = time.Now().Add(c.timeout << uint(i))
+		}
+		if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+		err = c.conn.SetReadDeadline(deadline)
+		if err != nil {
+			return nil, err
+		}
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Discard late responses to earlier requests until the deadline.
+		for {
+			n, err = c.conn.Read(buf)
+			if err != nil || !staleResponse(packet, buf[:n]) {
+				break
+			}
+			poke.Debugf("Discarding response with transaction ID %x\n", buf[4:8])
+		}
+		if err == nil {
+			return buf[:n], nil
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() && i < c.retries {
+			poke.Debugf("Timeout, retransmitting (%d/%d)\n", i+1, c.retries)
+			continue
+		}
+
+		return nil, err
+	}
+}
+
+// staleResponse reports whether resp carries a different transaction ID than
+// the request packet.
+// The transaction IDs of packets shorter than a request header can not be
+// compared, so their responses are never considered stale.
+func staleResponse(packet, resp []byte) bool {
+	return len(packet) >= 16 && len(resp) >= 8 && !bytes.Equal(resp[4:8], packet[12:16])
+}
+
+// receiveError is an error that occurred while waiting for the response to
+// a request.
+// It implements net.Error, so timeouts can be told apart from other errors.
+type receiveError struct {
+	op  string
+	err error
+}
+
+var _ net.Error = receiveError{}
+
+func (e receiveError) Error() string {
+	if e.Timeout() {
+		return fmt.Sprintf("%s: I/O timeout on receive", e.op)
+	}
+	return fmt.Sprintf("%s: %s", e.op, e.err)
+}
+
+// Timeout reports whether no response was received in time.
+func (e receiveError) Timeout() bool {
+	if netErr, ok := e.err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	return strings.HasSuffix(e.err.Error(), "i/o timeout")
+}
+
+// Temporary implements net.Error.
+func (e receiveError) Temporary() bool {
+	return e.Timeout()
+}
+
+// wrapReceiveError wraps an error returned by roundTrip.
+func wrapReceiveError(op string, err error) error {
+	return receiveError{op: op, err: err}
 }
 
 // ManualConnect performs a connect request and returns the connection ID.
 func (c *Client) ManualConnect() (uint64, error) {
-	return c.manualConnect()
+	return c.manualConnect(context.Background())
+}
+
+// ManualConnectContext performs a connect request and returns the connection
+// ID.
+// Waiting for the response is aborted if ctx is done.
+func (c *Client) ManualConnectContext(ctx context.Context) (uint64, error) {
+	return c.manualConnect(ctx)
 }
 
-func (c *Client) manualConnect() (uint64, error) {
-	transactionID := atomic.AddUint32(tid, 1)
+func (c *Client) manualConnect(ctx context.Context) (uint64, error) {
+	transactionID := c.nextTransactionID()
 
 	buf := make([]byte, 16)
 
@@ -81,30 +294,15 @@ func (c *Client) manualConnect() (uint64, error) {
 
 	binary.BigEndian.PutUint32(buf[12:16], transactionID)
 
-	n, err := c.conn.Write(buf)
-	if err != nil {
-		return 0, err
-	}
-
-	if n != 16 {
-		return 0, errors.New("connect: Did not send 16 bytes")
-	}
-
-	buf = make([]byte, 64)
-	n, err = c.conn.Read(buf)
+	b, err := c.roundTripper.RoundTrip(ctx, buf)
 	if err != nil {
-		if strings.HasSuffix(err.Error(), "i/o timeout") {
-			return 0, errors.New("connect: I/O timeout on receive")
-		}
-		return 0, fmt.Errorf("connect: %s", err)
+		return 0, wrapReceiveError("connect", err)
 	}
 
-	if n != 16 {
+	if len(b) != 16 {
 		return 0, errors.New("connect: Did not receive 16 bytes")
 	}
 
-	b := buf[:n]
-
 	action := binary.BigEndian.Uint32(b[:4])
 	if action != 0 {
 		return 0, errors.New("connect: action != 0")
@@ -119,6 +317,40 @@ func (c *Client) manualConnect() (uint64, error) {
 	return connID, nil
 }
 
+// invalidEventID is the event value sent for poke.EventInvalid.
+// BEP 15 only defines the values 0 to 3.
+const invalidEventID = 9
+
+// eventID maps a poke.Event to its BEP 15 value.
+func eventID(e poke.Event) (uint32, error) {
+	switch e {
+	case poke.EventNone:
+		return 0, nil
+	case poke.EventCompleted:
+		return 1, nil
+	case poke.EventStarted:
+		return 2, nil
+	case poke.EventStopped:
+		return 3, nil
+	case poke.EventInvalid:
+		return invalidEventID, nil
+	}
+
+	return 0, errors.New("unknown event")
+}
+
+// AnnouncePacket returns the announce request packet the Client sends for the
+// AnnounceRequest, including the URL data options.
+// Together with the Client's RoundTripper, this makes it possible to send
+// modified announces.
+func (c *Client) AnnouncePacket(req poke.AnnounceRequest, connID uint64, transactionID uint32) ([]byte, error) {
+	packet, err := prepareAnnounce(req, connID, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	return appendURLData(packet, c.urlData), nil
+}
+
 func prepareAnnounce(req poke.AnnounceRequest, connID uint64, transactionID uint32) ([]byte, error) {
 	bbuf := bytes.NewBuffer(nil)
 
@@ -181,19 +413,32 @@ func prepareAnnounce(req poke.AnnounceRequest, connID uint64, transactionID uint
 	}
 
 	// Event
-	_, err = bbuf.Write([]byte{0, 0, 0, 0x02})
+	event, err := eventID(req.Event)
+	if err != nil {
+		return nil, err
+	}
+	buf = make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, event)
+	_, err = bbuf.Write(buf)
 	if err != nil {
 		return nil, err
 	}
 
 	// IP Address
-	_, err = bbuf.Write(req.Peer.IP)
+	// The field is only four bytes long, IPv6 addresses can not be sent.
+	ip := req.Peer.IP.To4()
+	if ip == nil {
+		ip = make([]byte, 4)
+	}
+	_, err = bbuf.Write(ip)
 	if err != nil {
 		return nil, err
 	}
 
-	// "Key"
-	_, err = bbuf.Write([]byte{0, 0, 0, 0})
+	// Key
+	buf = make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, req.Key)
+	_, err = bbuf.Write(buf)
 	if err != nil {
 		return nil, err
 	}
@@ -223,50 +468,43 @@ func prepareAnnounce(req poke.AnnounceRequest, connID uint64, transactionID uint
 //
 // This implements poke.Announcer for UDP clients.
 func (c *Client) Announce(req poke.AnnounceRequest) (poke.OptionalAnnounceResponse, error) {
-	if ip := req.IP.To4(); ip != nil {
-		req.IP = ip
-	}
+	return c.AnnounceContext(context.Background(), req)
+}
 
+// AnnounceContext performs an announce for this client, like Announce.
+// Waiting for responses is aborted if ctx is done.
+//
+// This implements poke.ContextAnnouncer for UDP clients.
+func (c *Client) AnnounceContext(ctx context.Context, req poke.AnnounceRequest) (poke.OptionalAnnounceResponse, error) {
 	if poke.Debug {
 		log.Printf("Announcing: %+v", req)
 	}
 
 	if c.autoConnect {
-		connID, err := c.manualConnect()
+		connID, err := c.manualConnect(ctx)
 		if err != nil {
 			return nil, err
 		}
 		c.connectionID = connID
 	}
 
-	transactionID := atomic.AddUint32(tid, 1)
+	transactionID := c.nextTransactionID()
 	toReturn := poke.AnnounceResponse{
 		Peers: make([]poke.Peer, 0),
 	}
-	packet, err := prepareAnnounce(req, c.connectionID, transactionID)
+	packet, err := c.AnnouncePacket(req, c.connectionID, transactionID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Prepare a receive buffer.
-	buf := make([]byte, 1024)
-
-	// Send announce.
-	_, err = c.conn.Write(packet)
+	// Send announce and receive response.
+	buf, err := c.roundTripper.RoundTrip(ctx, packet)
 	if err != nil {
-		return nil, err
+		return nil, wrapReceiveError("announce", err)
 	}
-
-	// Receive response.
-	n, err := c.conn.Read(buf)
-	if err != nil {
-		if strings.HasSuffix(err.Error(), "i/o timeout") {
-			return nil, errors.New("announce: I/O timeout on receive")
-		}
-		return nil, fmt.Errorf("announce: %s", err)
-	}
-	if n < 20 {
-		return nil, errors.New("announce: Did not receive at least 20 bytes")
+	n := len(buf)
+	if n < 8 {
+		return nil, errors.New("announce: Did not receive at least 8 bytes")
 	}
 
 	// Check transaction ID.
@@ -286,20 +524,34 @@ func (c *Client) Announce(req poke.AnnounceRequest) (poke.OptionalAnnounceRespon
 		return nil, errors.New("announce: tracker responded with action != 1")
 	}
 
+	// Error responses can be shorter than announce responses.
+	if n < 20 {
+		return nil, errors.New("announce: Did not receive at least 20 bytes")
+	}
+
 	toReturn.Interval = int(binary.BigEndian.Uint32(buf[8:12]))
 	toReturn.Incomplete = int(binary.BigEndian.Uint32(buf[12:16]))
 	toReturn.Complete = int(binary.BigEndian.Uint32(buf[16:20]))
 
-	if (n-20)%6 != 0 {
+	// Peers are 6 bytes (IPv4) or 18 bytes (IPv6) long, depending on the
+	// address family used to talk to the tracker.
+	ipLen := net.IPv4len
+	if c.ipv6 {
+		ipLen = net.IPv6len
+	}
+	peerLen := ipLen + 2
+
+	if (n-20)%peerLen != 0 {
 		return nil, fmt.Errorf("announce: unexpected announce response length: %d", n)
 	}
 
-	numPeers := (n - 20) / 6
+	numPeers := (n - 20) / peerLen
 	for i := 0; i < numPeers; i++ {
+		b := buf[20+peerLen*i : 20+peerLen*(i+1)]
 		toReturn.Peers = append(toReturn.Peers,
 			poke.Peer{
-				IP:   net.IP(buf[20+6*i : 24+6*i]),
-				Port: binary.BigEndian.Uint16(buf[24+6*i : 26+6*i]),
+				IP:   net.IP(append([]byte(nil), b[:ipLen]...)),
+				Port: binary.BigEndian.Uint16(b[ipLen:]),
 			})
 	}
 
@@ -0,0 +1,276 @@
+package udp
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+func TestPrepareAnnounce(t *testing.T) {
+	req := poke.AnnounceRequest{
+		InfoHash:   poke.InfoHash("aaaaaaaaaaaaaaaaaaaa"),
+		Uploaded:   1,
+		Downloaded: 2,
+		Left:       3,
+		Numwant:    50,
+		Peer: poke.Peer{
+			ID:   "-POKE64-000000012345",
+			Port: 12345,
+			IP:   net.IPv4(1, 2, 3, 4).To4(),
+		},
+	}
+
+	table := []struct {
+		event poke.Event
+		id    uint32
+	}{
+		{poke.EventNone, 0},
+		{poke.EventCompleted, 1},
+		{poke.EventStarted, 2},
+		{poke.EventStopped, 3},
+		{poke.EventInvalid, invalidEventID},
+	}
+
+	for _, tt := range table {
+		req.Event = tt.event
+		packet, err := prepareAnnounce(req, 0x0102030405060708, 0x0a0b0c0d)
+		require.Nil(t, err)
+		require.Equal(t, 98, len(packet))
+
+		assert.Equal(t, uint64(0x0102030405060708), binary.BigEndian.Uint64(packet[0:8]))
+		assert.Equal(t, uint32(1), binary.BigEndian.Uint32(packet[8:12]))
+		assert.Equal(t, uint32(0x0a0b0c0d), binary.BigEndian.Uint32(packet[12:16]))
+		assert.Equal(t, []byte(req.InfoHash), packet[16:36])
+		assert.Equal(t, []byte(req.ID), packet[36:56])
+		assert.Equal(t, uint64(2), binary.BigEndian.Uint64(packet[56:64]))
+		assert.Equal(t, uint64(3), binary.BigEndian.Uint64(packet[64:72]))
+		assert.Equal(t, uint64(1), binary.BigEndian.Uint64(packet[72:80]))
+		assert.Equal(t, tt.id, binary.BigEndian.Uint32(packet[80:84]))
+		assert.Equal(t, []byte{1, 2, 3, 4}, packet[84:88])
+		assert.Equal(t, uint32(0), binary.BigEndian.Uint32(packet[88:92]))
+		assert.Equal(t, uint32(50), binary.BigEndian.Uint32(packet[92:96]))
+		assert.Equal(t, uint16(12345), binary.BigEndian.Uint16(packet[96:98]))
+	}
+
+	req.Event = 42
+	_, err := prepareAnnounce(req, 0, 0)
+	assert.NotNil(t, err)
+}
+
+func TestPrepareAnnounceIPv6(t *testing.T) {
+	req := poke.AnnounceRequest{
+		InfoHash: poke.InfoHash("aaaaaaaaaaaaaaaaaaaa"),
+		Peer: poke.Peer{
+			ID:   "-POKE64-000000012345",
+			Port: 12345,
+			IP:   net.ParseIP("2001:db8::1"),
+		},
+	}
+
+	packet, err := prepareAnnounce(req, 0, 0)
+	require.Nil(t, err)
+	require.Equal(t, 98, len(packet))
+	assert.Equal(t, []byte{0, 0, 0, 0}, packet[84:88])
+	assert.Equal(t, uint16(12345), binary.BigEndian.Uint16(packet[96:98]))
+}
+
+func TestAnnounceIPv6(t *testing.T) {
+	conn, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback not available:", err)
+	}
+	defer conn.Close()
+
+	peer := poke.Peer{
+		IP:   net.ParseIP("2001:db8::6464:1"),
+		Port: 6464,
+	}
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 8, 64)
+			copy(resp[4:8], buf[12:16])
+			switch binary.BigEndian.Uint32(buf[8:12]) {
+			case 0:
+				resp = append(resp, 1, 2, 3, 4, 5, 6, 7, 8)
+			case 1:
+				resp[3] = 1
+				resp = append(resp, 0, 0, 0, 60, 0, 0, 0, 1, 0, 0, 0, 0)
+				resp = append(resp, peer.IP...)
+				resp = append(resp, byte(peer.Port>>8), byte(peer.Port))
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	c, err := NewClient(conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	require.True(t, c.IPv6())
+
+	resp, err := c.Announce(poke.AnnounceRequest{
+		InfoHash: poke.InfoHash("aaaaaaaaaaaaaaaaaaaa"),
+		Peer: poke.Peer{
+			ID:   "-POKE64-000000012345",
+			Port: 12345,
+			IP:   net.ParseIP("2001:db8::1"),
+		},
+	})
+	require.Nil(t, err)
+	require.IsType(t, poke.AnnounceResponse{}, resp)
+
+	ann := resp.(poke.AnnounceResponse)
+	assert.Equal(t, 60, ann.Interval)
+	assert.Equal(t, 1, ann.Incomplete)
+	require.Equal(t, 1, len(ann.Peers))
+	assert.True(t, peer.IP.Equal(ann.Peers[0].IP))
+	assert.Equal(t, peer.Port, ann.Peers[0].Port)
+}
+
+func TestRoundTripRetransmit(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// Drop the first two packets, echo the third one.
+	received := make(chan int, 3)
+	go func() {
+		buf := make([]byte, 2048)
+		for i := 0; ; i++ {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			received <- i
+			if i < 2 {
+				continue
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	c, err := NewClient(conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	c.SetTimeout(10 * time.Millisecond)
+
+	c.SetRetries(1)
+	_, err = c.roundTrip(context.Background(), []byte("hello"))
+	require.NotNil(t, err)
+	assert.True(t, err.(net.Error).Timeout())
+
+	err = wrapReceiveError("announce", err)
+	assert.True(t, err.(net.Error).Timeout())
+	assert.Equal(t, "announce: I/O timeout on receive", err.Error())
+
+	c.SetRetries(2)
+	b, err := c.roundTrip(context.Background(), []byte("hello"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("hello"), b)
+	assert.Equal(t, 3, len(received))
+}
+
+func TestRoundTripNoTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// Echo the packet after a while.
+	go func() {
+		buf := make([]byte, 2048)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		conn.WriteTo(buf[:n], addr)
+	}()
+
+	c, err := NewClient(conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	c.SetTimeout(0)
+
+	b, err := c.roundTrip(context.Background(), []byte("hello"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("hello"), b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.roundTrip(ctx, []byte("hello"))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestClose(t *testing.T) {
+	c, err := NewClient("127.0.0.1:1")
+	require.Nil(t, err)
+	require.Nil(t, c.Close())
+
+	_, err = c.Announce(poke.AnnounceRequest{})
+	assert.NotNil(t, err)
+}
+
+func TestRoundTripDiscardsStaleResponses(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// Answer every packet with a response to another transaction first.
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			stale := make([]byte, 8)
+			binary.BigEndian.PutUint32(stale[4:8], binary.BigEndian.Uint32(buf[12:16])+1)
+			conn.WriteTo(stale, addr)
+			conn.WriteTo(append(make([]byte, 4), buf[12:n]...), addr)
+		}
+	}()
+
+	c, err := NewClient(conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	c.SetTimeout(time.Second)
+
+	packet := make([]byte, 16)
+	binary.BigEndian.PutUint32(packet[12:16], 42)
+	b, err := c.roundTrip(context.Background(), packet)
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 42}, b)
+}
+
+func TestAnnounceContextCanceled(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	c, err := NewClient(conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err = c.AnnounceContext(ctx, poke.AnnounceRequest{})
+	require.NotNil(t, err)
+	assert.True(t, time.Since(start) < DefaultTimeout)
+}
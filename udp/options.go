@@ -0,0 +1,90 @@
+package udp
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Option types, as specified in BEP 41.
+const (
+	optionEndOfOptions = 0x0
+	optionNOP          = 0x1
+	optionURLData      = 0x2
+)
+
+// maxOptionLength is the maximum length of the data of a single option.
+const maxOptionLength = 255
+
+// urlScheme is the scheme of UDP tracker URLs.
+const urlScheme = "udp://"
+
+// parseAddress splits a tracker address into the address to send packets to
+// and the URL data to send with announces.
+//
+// The address is either host:port, or a URL of the form
+// udp://host:port/path?query, where the path and query are the URL data.
+func parseAddress(addr string) (string, string, error) {
+	if !strings.HasPrefix(addr, urlScheme) {
+		return addr, "", nil
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		return "", "", ErrInvalidAddress
+	}
+
+	urlData := u.EscapedPath()
+	if u.RawQuery != "" {
+		urlData += "?" + u.RawQuery
+	}
+
+	return u.Host, urlData, nil
+}
+
+// appendURLData appends the URL data to a packet as URL data options,
+// followed by an EndOfOptions option.
+// URL data longer than maxOptionLength is split across multiple options.
+func appendURLData(packet []byte, urlData string) []byte {
+	if urlData == "" {
+		return packet
+	}
+
+	for len(urlData) > 0 {
+		n := len(urlData)
+		if n > maxOptionLength {
+			n = maxOptionLength
+		}
+		packet = append(packet, optionURLData, byte(n))
+		packet = append(packet, urlData[:n]...)
+		urlData = urlData[n:]
+	}
+
+	return append(packet, optionEndOfOptions)
+}
+
+// ParseURLData parses the options following an announce and returns the URL
+// data they contain.
+// NOP options are skipped, parsing stops at an EndOfOptions option or at
+// an unknown option.
+func ParseURLData(options []byte) (string, error) {
+	var urlData []byte
+	for len(options) > 0 {
+		switch options[0] {
+		case optionEndOfOptions:
+			return string(urlData), nil
+		case optionNOP:
+			options = options[1:]
+		case optionURLData:
+			if len(options) < 2 || len(options) < 2+int(options[1]) {
+				return "", ErrMalformedOptions
+			}
+			n := int(options[1])
+			urlData = append(urlData, options[2:2+n]...)
+			options = options[2+n:]
+		default:
+			return string(urlData), nil
+		}
+	}
+
+	return string(urlData), nil
+}
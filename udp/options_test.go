@@ -0,0 +1,71 @@
+package udp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAddress(t *testing.T) {
+	table := []struct {
+		addr    string
+		host    string
+		urlData string
+	}{
+		{"localhost:1234", "localhost:1234", ""},
+		{"udp://localhost:1234", "localhost:1234", ""},
+		{"udp://localhost:1234/announce", "localhost:1234", "/announce"},
+		{"udp://localhost:1234/dir?a=b&c=d", "localhost:1234", "/dir?a=b&c=d"},
+	}
+
+	for _, tt := range table {
+		host, urlData, err := parseAddress(tt.addr)
+		require.Nil(t, err, tt.addr)
+		assert.Equal(t, tt.host, host, tt.addr)
+		assert.Equal(t, tt.urlData, urlData, tt.addr)
+	}
+
+	_, _, err := parseAddress("udp:///announce")
+	assert.Equal(t, ErrInvalidAddress, err)
+}
+
+func TestAppendURLData(t *testing.T) {
+	assert.Equal(t, []byte{1}, appendURLData([]byte{1}, ""))
+
+	assert.Equal(t, []byte{1, optionURLData, 3, '/', 'a', 'b', optionEndOfOptions},
+		appendURLData([]byte{1}, "/ab"))
+
+	long := "/" + strings.Repeat("a", 300)
+	b := appendURLData(nil, long)
+	require.Equal(t, 2+255+2+46+1, len(b))
+	assert.Equal(t, []byte{optionURLData, 255}, b[:2])
+	assert.Equal(t, []byte{optionURLData, 46}, b[257:259])
+	assert.Equal(t, byte(optionEndOfOptions), b[len(b)-1])
+
+	urlData, err := ParseURLData(b)
+	require.Nil(t, err)
+	assert.Equal(t, long, urlData)
+}
+
+func TestParseURLData(t *testing.T) {
+	table := []struct {
+		options []byte
+		urlData string
+	}{
+		{nil, ""},
+		{[]byte{optionEndOfOptions, optionURLData, 1, 'a'}, ""},
+		{[]byte{optionNOP, optionURLData, 2, '/', 'a', optionNOP, optionURLData, 1, 'b'}, "/ab"},
+		{[]byte{optionURLData, 1, 'a', 0x42, optionURLData, 1, 'b'}, "a"},
+	}
+
+	for _, tt := range table {
+		urlData, err := ParseURLData(tt.options)
+		require.Nil(t, err)
+		assert.Equal(t, tt.urlData, urlData)
+	}
+
+	_, err := ParseURLData([]byte{optionURLData, 5, 'a'})
+	assert.Equal(t, ErrMalformedOptions, err)
+}
@@ -0,0 +1,151 @@
+package udp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+// MaxScrapeInfoHashes is the maximum number of infohashes that can be scraped
+// with a single UDP scrape request.
+const MaxScrapeInfoHashes = 74
+
+// ErrTooManyInfoHashes indicates that a scrape request contained more than
+// MaxScrapeInfoHashes infohashes.
+var ErrTooManyInfoHashes = errors.New("too many infohashes")
+
+var (
+	_ poke.Scraper        = &Client{}
+	_ poke.ContextScraper = &Client{}
+)
+
+func prepareScrape(req poke.ScrapeRequest, connID uint64, transactionID uint32) ([]byte, error) {
+	bbuf := bytes.NewBuffer(nil)
+
+	// Connection ID
+	var buf = make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, connID)
+	_, err := bbuf.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Action
+	_, err = bbuf.Write([]byte{0, 0, 0, 0x02})
+	if err != nil {
+		return nil, err
+	}
+
+	// Transaction ID
+	buf = make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, transactionID)
+	_, err = bbuf.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Infohashes
+	for _, ih := range req.InfoHashes {
+		_, err = bbuf.Write(ih)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bbuf.Bytes(), nil
+}
+
+// Scrape performs a scrape for this client.
+// If autoConnect is enabled, the client will first perform a connect request to
+// obtain a connection ID.
+//
+// The files of the response are returned in the order of the infohashes in
+// the request, as mandated by BEP 15.
+//
+// This implements poke.Scraper for UDP clients.
+func (c *Client) Scrape(req poke.ScrapeRequest) (poke.OptionalScrapeResponse, error) {
+	return c.ScrapeContext(context.Background(), req)
+}
+
+// ScrapeContext performs a scrape for this client, like Scrape.
+// Waiting for responses is aborted if ctx is done.
+//
+// This implements poke.ContextScraper for UDP clients.
+func (c *Client) ScrapeContext(ctx context.Context, req poke.ScrapeRequest) (poke.OptionalScrapeResponse, error) {
+	if len(req.InfoHashes) > MaxScrapeInfoHashes {
+		return nil, ErrTooManyInfoHashes
+	}
+
+	if poke.Debug {
+		log.Printf("Scraping: %+v", req)
+	}
+
+	if c.autoConnect {
+		connID, err := c.manualConnect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.connectionID = connID
+	}
+
+	transactionID := c.nextTransactionID()
+	toReturn := poke.ScrapeResponse{
+		Files: make([]poke.Scrape, 0, len(req.InfoHashes)),
+	}
+	packet, err := prepareScrape(req, c.connectionID, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send scrape and receive response.
+	buf, err := c.roundTripper.RoundTrip(ctx, packet)
+	if err != nil {
+		return nil, wrapReceiveError("scrape", err)
+	}
+	n := len(buf)
+	if n < 8 {
+		return nil, errors.New("scrape: Did not receive at least 8 bytes")
+	}
+
+	// Check transaction ID.
+	transID := binary.BigEndian.Uint32(buf[4:8])
+	if transID != transactionID {
+		return nil, errors.New("scrape: transaction IDs do not match")
+	}
+
+	// Parse action.
+	action := binary.BigEndian.Uint32(buf[:4])
+	if action != 2 {
+		if action == 3 {
+			errVal := string(buf[8:n])
+			return poke.ErrorResponse(errVal), nil
+		}
+		return nil, errors.New("scrape: tracker responded with action != 2")
+	}
+
+	if n != 8+12*len(req.InfoHashes) {
+		return nil, fmt.Errorf("scrape: unexpected scrape response length: %d", n)
+	}
+
+	for i, ih := range req.InfoHashes {
+		b := buf[8+12*i : 20+12*i]
+		toReturn.Files = append(toReturn.Files,
+			poke.Scrape{
+				InfoHash:   ih,
+				Complete:   int(binary.BigEndian.Uint32(b[0:4])),
+				Downloaded: int(binary.BigEndian.Uint32(b[4:8])),
+				Incomplete: int(binary.BigEndian.Uint32(b[8:12])),
+			})
+	}
+
+	if poke.Debug {
+		log.Printf("Got scrape response: %+v", toReturn)
+	}
+
+	return toReturn, nil
+}
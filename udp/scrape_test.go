@@ -0,0 +1,81 @@
+package udp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrd0ll4r/poke"
+)
+
+func TestPrepareScrape(t *testing.T) {
+	ih1 := poke.InfoHash("aaaaaaaaaaaaaaaaaaaa")
+	ih2 := poke.InfoHash("bbbbbbbbbbbbbbbbbbbb")
+
+	packet, err := prepareScrape(poke.ScrapeRequest{InfoHashes: []poke.InfoHash{ih1, ih2}}, 0x0102030405060708, 0x0a0b0c0d)
+	require.Nil(t, err)
+	require.Equal(t, 16+2*20, len(packet))
+
+	assert.Equal(t, uint64(0x0102030405060708), binary.BigEndian.Uint64(packet[0:8]))
+	assert.Equal(t, uint32(2), binary.BigEndian.Uint32(packet[8:12]))
+	assert.Equal(t, uint32(0x0a0b0c0d), binary.BigEndian.Uint32(packet[12:16]))
+	assert.Equal(t, []byte(ih1), packet[16:36])
+	assert.Equal(t, []byte(ih2), packet[36:56])
+}
+
+func TestScrape(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 8, 64)
+			copy(resp[4:8], buf[12:16])
+			switch binary.BigEndian.Uint32(buf[8:12]) {
+			case 0:
+				resp = append(resp, 1, 2, 3, 4, 5, 6, 7, 8)
+			case 2:
+				resp[3] = 2
+				for i := 0; i < (n-16)/20; i++ {
+					triple := make([]byte, 12)
+					binary.BigEndian.PutUint32(triple[0:4], uint32(3*i+1))
+					binary.BigEndian.PutUint32(triple[4:8], uint32(3*i+2))
+					binary.BigEndian.PutUint32(triple[8:12], uint32(3*i+3))
+					resp = append(resp, triple...)
+				}
+			default:
+				resp[3] = 3
+				resp = append(resp, []byte("invalid action")...)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	c, err := NewClient(conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+
+	ih1 := poke.InfoHash("aaaaaaaaaaaaaaaaaaaa")
+	ih2 := poke.InfoHash("bbbbbbbbbbbbbbbbbbbb")
+
+	resp, err := c.Scrape(poke.ScrapeRequest{InfoHashes: []poke.InfoHash{ih1, ih2}})
+	require.Nil(t, err)
+	require.IsType(t, poke.ScrapeResponse{}, resp)
+
+	files := resp.(poke.ScrapeResponse).Files
+	require.Equal(t, 2, len(files))
+	assert.Equal(t, poke.Scrape{InfoHash: ih1, Complete: 1, Downloaded: 2, Incomplete: 3}, files[0])
+	assert.Equal(t, poke.Scrape{InfoHash: ih2, Complete: 4, Downloaded: 5, Incomplete: 6}, files[1])
+
+	_, err = c.Scrape(poke.ScrapeRequest{InfoHashes: make([]poke.InfoHash, MaxScrapeInfoHashes+1)})
+	assert.Equal(t, ErrTooManyInfoHashes, err)
+}